@@ -337,6 +337,16 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/state",
 			HandlerFunc: a.NodeSetState},
+		rest.Route{
+			Name:        "NodeSetTags",
+			Method:      "POST",
+			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/tags",
+			HandlerFunc: a.NodeSetTags},
+		rest.Route{
+			Name:        "NodePing",
+			Method:      "GET",
+			Pattern:     "/nodes/{id:[A-Fa-f0-9]+}/ping",
+			HandlerFunc: a.NodePing},
 
 		// Devices
 		rest.Route{
@@ -359,11 +369,21 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/state",
 			HandlerFunc: a.DeviceSetState},
+		rest.Route{
+			Name:        "DeviceSetTags",
+			Method:      "POST",
+			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/tags",
+			HandlerFunc: a.DeviceSetTags},
 		rest.Route{
 			Name:        "DeviceResync",
 			Method:      "GET",
 			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/resync",
 			HandlerFunc: a.DeviceResync},
+		rest.Route{
+			Name:        "DeviceBrickScan",
+			Method:      "GET",
+			Pattern:     "/devices/{id:[A-Fa-f0-9]+}/scan",
+			HandlerFunc: a.DeviceBrickScan},
 
 		// Volume
 		rest.Route{
@@ -381,6 +401,21 @@ func (a *App) SetRoutes(router *mux.Router) error {
 			Method:      "POST",
 			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/expand",
 			HandlerFunc: a.VolumeExpand},
+		rest.Route{
+			Name:        "VolumeUpdate",
+			Method:      "POST",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/update",
+			HandlerFunc: a.VolumeUpdate},
+		rest.Route{
+			Name:        "VolumeBrickSets",
+			Method:      "GET",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/bricksets",
+			HandlerFunc: a.VolumeBrickSets},
+		rest.Route{
+			Name:        "VolumeBrickStatus",
+			Method:      "GET",
+			Pattern:     "/volumes/{id:[A-Fa-f0-9]+}/bricks",
+			HandlerFunc: a.VolumeBrickStatus},
 		rest.Route{
 			Name:        "VolumeDelete",
 			Method:      "DELETE",
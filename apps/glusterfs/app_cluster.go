@@ -76,6 +76,15 @@ func (a *App) ClusterSetFlags(w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
+		err = entry.CheckSetFlags(tx, msg.ClusterFlags)
+		if err == ErrConflict {
+			http.Error(w, entry.SetFlagsConflictString(), http.StatusConflict)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return logger.Err(err)
+		}
+
 		entry.Info.File = msg.File
 		entry.Info.Block = msg.Block
 
@@ -459,3 +459,83 @@ func TestClusterDelete(t *testing.T) {
 	tests.Assert(t, err == nil, err)
 
 }
+
+func TestClusterSetFlagsInUse(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Store a cluster with a file volume and a block hosting volume
+	entry := NewClusterEntry()
+	entry.Info.Id = "123abc"
+	entry.Info.File = true
+	entry.Info.Block = true
+
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		for _, block := range []bool{false, true} {
+			v := NewVolumeEntry()
+			v.Info.Id = utils.GenUUID()
+			v.Info.Cluster = entry.Info.Id
+			v.Info.Block = block
+			err := v.Save(tx)
+			if err != nil {
+				return err
+			}
+			entry.VolumeAdd(v.Info.Id)
+		}
+		entry.BlockVolumeAdd(utils.GenUUID())
+		return entry.Save(tx)
+	})
+	tests.Assert(t, err == nil, err)
+
+	setFlags := func(request string) *http.Response {
+		r, err := http.Post(ts.URL+"/clusters/"+entry.Info.Id+"/flags",
+			"application/json", bytes.NewBufferString(request))
+		tests.Assert(t, err == nil, err)
+		return r
+	}
+
+	// Capabilities still in use cannot be disabled
+	r := setFlags(`{"file": true, "block": false}`)
+	tests.Assert(t, r.StatusCode == http.StatusConflict, r.StatusCode)
+	r = setFlags(`{"file": false, "block": true}`)
+	tests.Assert(t, r.StatusCode == http.StatusConflict, r.StatusCode)
+	s, err := utils.GetStringFromResponse(r)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, s == entry.SetFlagsConflictString()+"\n", s)
+
+	var ce ClusterEntry
+	err = app.db.View(func(tx *bolt.Tx) error {
+		return ce.Unmarshal(
+			tx.Bucket([]byte(BOLTDB_BUCKET_CLUSTER)).
+				Get([]byte(entry.Info.Id)))
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, ce.Info.File && ce.Info.Block, ce.Info)
+
+	// Once the file volume is gone, file volumes can be disabled
+	err = app.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range ce.Info.Volumes {
+			v, err := NewVolumeEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+			if !v.Info.Block {
+				ce.VolumeDelete(id)
+			}
+		}
+		return ce.Save(tx)
+	})
+	tests.Assert(t, err == nil, err)
+	r = setFlags(`{"file": false, "block": true}`)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+}
@@ -340,6 +340,67 @@ func (a *App) DeviceSetState(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (a *App) DeviceSetTags(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Unmarshal JSON
+	var msg api.TagsChangeRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		http.Error(w, "request unable to be parsed", 422)
+		return
+	}
+	err = msg.Validate()
+	if err != nil {
+		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		logger.LogError("validation failed: %v", err)
+		return
+	}
+
+	// Replace the tags and read back the device
+	var info *api.DeviceInfoResponse
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		device, err := NewDeviceEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		device.Tags = nil
+		if len(msg.Tags) != 0 {
+			device.Tags = msg.Tags
+		}
+		err = device.Save(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		info, err = device.NewInfoResponse(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
+
 func (a *App) DeviceResync(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
@@ -433,3 +494,55 @@ func (a *App) DeviceResync(w http.ResponseWriter, r *http.Request) {
 		return "", err
 	})
 }
+
+// DeviceBrickScan lists the bricks found in the volume group of the
+// device on its node. Unlike the device info it also finds bricks which
+// were left behind after heketi removed them from its database.
+func (a *App) DeviceBrickScan(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	deviceId := vars["id"]
+
+	var (
+		device *DeviceEntry
+		node   *NodeEntry
+	)
+
+	// Get device info from DB
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		device, err = NewDeviceEntryFromId(tx, deviceId)
+		if err != nil {
+			return err
+		}
+		node, err = NewNodeEntryFromId(tx, device.NodeId)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+	if err == ErrNotFound {
+		http.Error(w, "Id not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.Err(err)
+		return
+	}
+
+	bricks, err := a.executor.DeviceBricks(node.ManageHostName(), device.Info.Id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.LogError("Unable to scan device %v: %v", device.Info.Id, err)
+		return
+	}
+
+	info := api.DeviceBrickScanResponse{
+		Bricks: bricks,
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
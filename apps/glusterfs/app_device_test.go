@@ -11,9 +11,11 @@ package glusterfs
 
 import (
 	"bytes"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"sort"
 	"testing"
 	"time"
@@ -882,3 +884,120 @@ func TestDeviceSyncIdNotFound(t *testing.T) {
 	tests.Assert(t, err == nil)
 	tests.Assert(t, r.StatusCode == http.StatusNotFound)
 }
+
+func TestDeviceSetTags(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a device to save in the db
+	device := NewDeviceEntry()
+	device.Info.Id = "abc"
+	device.Info.Name = "/dev/fake1"
+	device.NodeId = "def"
+	device.StorageSet(10000)
+
+	// Save device in the db
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		return device.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown device
+	request := []byte(`{"tags": {"disk": "ssd"}}`)
+	r, err := http.Post(ts.URL+"/devices/123/tags", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	// Set the tags, the device is returned
+	r, err = http.Post(ts.URL+"/devices/abc/tags", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	var info api.DeviceInfoResponse
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Id == "abc")
+	tests.Assert(t, info.Tags["disk"] == "ssd", info.Tags)
+
+	// Tags are stored with the device
+	err = app.db.View(func(tx *bolt.Tx) error {
+		device, err = NewDeviceEntryFromId(tx, "abc")
+		return err
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, device.Tags["disk"] == "ssd", device.Tags)
+}
+
+func TestDeviceBrickScan(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	nodeId := utils.GenUUID()
+	deviceId := utils.GenUUID()
+
+	// Init test database
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		device := NewDeviceEntry()
+		device.Info.Id = deviceId
+		device.Info.Name = "/dev/abc"
+		device.NodeId = nodeId
+		device.StorageSet(10000)
+		if err := device.Save(tx); err != nil {
+			return err
+		}
+
+		node := NewNodeEntry()
+		node.Info.Id = nodeId
+		node.Info.Hostnames.Manage = sort.StringSlice{"manage.system"}
+		node.Info.Hostnames.Storage = sort.StringSlice{"storage.system"}
+		node.DeviceAdd(device.Info.Id)
+		return node.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown device
+	r, err := http.Get(ts.URL + "/devices/" + utils.GenUUID() + "/scan")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	// The volume group of the device is scanned on its node
+	app.xo.MockDeviceBricks = func(host, vgid string) ([]string, error) {
+		tests.Assert(t, host == "manage.system", host)
+		tests.Assert(t, vgid == deviceId, vgid)
+		return []string{"b1", "b2"}, nil
+	}
+	r, err = http.Get(ts.URL + "/devices/" + deviceId + "/scan")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+	var msg api.DeviceBrickScanResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, reflect.DeepEqual(msg.Bricks, []string{"b1", "b2"}), msg.Bricks)
+
+	// The node cannot be reached
+	app.xo.MockDeviceBricks = func(host, vgid string) ([]string, error) {
+		return nil, errors.New("connection refused")
+	}
+	r, err = http.Get(ts.URL + "/devices/" + deviceId + "/scan")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusInternalServerError, r.StatusCode)
+}
@@ -12,6 +12,7 @@ package glusterfs
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/mux"
@@ -212,6 +213,52 @@ func (a *App) NodeInfo(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (a *App) NodePing(w http.ResponseWriter, r *http.Request) {
+
+	// Get node id from URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Get Node information
+	var node *NodeEntry
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		node, err = NewNodeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Check glusterd regardless of the node state, so that offline
+	// nodes can be checked before they are brought back online
+	start := time.Now()
+	err = a.executor.GlusterdCheck(node.ManageHostName())
+	info := api.NodePingResponse{
+		Id:      id,
+		Up:      err == nil,
+		Latency: int64(time.Since(start) / time.Millisecond),
+	}
+	if err != nil {
+		info.Reason = err.Error()
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
+
 func (a *App) NodeDelete(w http.ResponseWriter, r *http.Request) {
 	// Get the id from the URL
 	vars := mux.Vars(r)
@@ -382,3 +429,64 @@ func (a *App) NodeSetState(w http.ResponseWriter, r *http.Request) {
 	})
 
 }
+
+func (a *App) NodeSetTags(w http.ResponseWriter, r *http.Request) {
+	// Get the id from the URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// Unmarshal JSON
+	var msg api.TagsChangeRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		http.Error(w, "request unable to be parsed", 422)
+		return
+	}
+	err = msg.Validate()
+	if err != nil {
+		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		logger.LogError("validation failed: %v", err)
+		return
+	}
+
+	// Replace the tags and read back the node
+	var info *api.NodeInfoResponse
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		node, err := NewNodeEntryFromId(tx, id)
+		if err == ErrNotFound {
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return err
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		node.Tags = nil
+		if len(msg.Tags) != 0 {
+			node.Tags = msg.Tags
+		}
+		err = node.Save(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		info, err = node.NewInfoReponse(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Write msg
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
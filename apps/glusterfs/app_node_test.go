@@ -1416,3 +1416,135 @@ func TestNodeInfoAfterDelete(t *testing.T) {
 	tests.Assert(t, r.StatusCode == http.StatusNotFound)
 
 }
+
+func TestNodePing(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a node to save in the db
+	node := NewNodeEntry()
+	node.Info.Id = "abc"
+	node.Info.ClusterId = "123"
+	node.Info.Hostnames.Manage = sort.StringSlice{"manage.system"}
+	node.Info.Hostnames.Storage = sort.StringSlice{"storage.system"}
+	node.State = api.EntryStateOffline
+
+	// Save node in the db
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		return node.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown node
+	r, err := http.Get(ts.URL + "/nodes/123/ping")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	// Offline nodes are checked too
+	var checked string
+	app.xo.MockGlusterdCheck = func(host string) error {
+		checked = host
+		return nil
+	}
+	r, err = http.Get(ts.URL + "/nodes/" + node.Info.Id + "/ping")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	var info api.NodePingResponse
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, checked == "manage.system", checked)
+	tests.Assert(t, info.Id == node.Info.Id && info.Up && info.Reason == "", info)
+
+	app.xo.MockGlusterdCheck = func(host string) error {
+		return errors.New("glusterd is not running")
+	}
+	r, err = http.Get(ts.URL + "/nodes/" + node.Info.Id + "/ping")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	info = api.NodePingResponse{}
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, !info.Up && info.Reason == "glusterd is not running", info)
+}
+
+func TestNodeSetTags(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Create a node to save in the db
+	node := NewNodeEntry()
+	node.Info.Id = "abc"
+	node.Info.ClusterId = "123"
+	node.Info.Hostnames.Manage = sort.StringSlice{"manage.system"}
+	node.Info.Hostnames.Storage = sort.StringSlice{"storage.system"}
+
+	// Save node in the db
+	err := app.db.Update(func(tx *bolt.Tx) error {
+		return node.Save(tx)
+	})
+	tests.Assert(t, err == nil)
+
+	// Unknown node
+	request := []byte(`{"tags": {"rack": "r1"}}`)
+	r, err := http.Post(ts.URL+"/nodes/123/tags", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	// Invalid key
+	request = []byte(`{"tags": {"-rack": "r1"}}`)
+	r, err = http.Post(ts.URL+"/nodes/abc/tags", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusBadRequest)
+
+	// Set the tags, the node is returned
+	request = []byte(`{"tags": {"rack": "r1", "zone": "east"}}`)
+	r, err = http.Post(ts.URL+"/nodes/abc/tags", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	var info api.NodeInfoResponse
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Id == "abc")
+	tests.Assert(t, len(info.Tags) == 2 && info.Tags["rack"] == "r1", info.Tags)
+
+	// Tags are stored with the node
+	r, err = http.Get(ts.URL + "/nodes/abc")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	info = api.NodeInfoResponse{}
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Tags["zone"] == "east", info.Tags)
+
+	// An empty map removes all tags
+	request = []byte(`{"tags": {}}`)
+	r, err = http.Post(ts.URL+"/nodes/abc/tags", "application/json", bytes.NewBuffer(request))
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK)
+	err = app.db.View(func(tx *bolt.Tx) error {
+		node, err = NewNodeEntryFromId(tx, "abc")
+		return err
+	})
+	tests.Assert(t, err == nil)
+	tests.Assert(t, node.Tags == nil, node.Tags)
+}
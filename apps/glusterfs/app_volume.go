@@ -274,6 +274,179 @@ func (a *App) VolumeDelete(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a *App) VolumeBrickSets(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var volume *VolumeEntry
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volume, err = NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound || (err == nil && !volume.Visible()) {
+			// treat an invisible entry like it doesn't exist
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return ErrNotFound
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	// Only gluster knows which bricks form a set
+	host, err := GetVerifiedManageHostname(a.db, a.executor, volume.Info.Cluster)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sets, err := volume.brickSets(a.db, a.executor, host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	info := api.VolumeBrickSetsResponse{
+		Sets: sets,
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) VolumeBrickStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var volume *VolumeEntry
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		volume, err = NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound || (err == nil && !volume.Visible()) {
+			// treat an invisible entry like it doesn't exist
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return ErrNotFound
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	host, err := GetVerifiedManageHostname(a.db, a.executor, volume.Info.Cluster)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	bricks, err := volume.brickStatus(a.db, a.executor, host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	info := api.VolumeBrickStatusResponse{
+		Bricks: bricks,
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
+
+// VolumeUpdate replaces the tags of a volume. Gluster cannot rename a
+// volume, so a request for a different name is refused, as a conflict
+// if another volume of the cluster already has the name.
+func (a *App) VolumeUpdate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var msg api.VolumeUpdateRequest
+	err := utils.GetJsonFromRequest(r, &msg)
+	if err != nil {
+		http.Error(w, "request unable to be parsed", 422)
+		return
+	}
+	err = msg.Validate()
+	if err != nil {
+		http.Error(w, "validation failed: "+err.Error(), http.StatusBadRequest)
+		logger.LogError("validation failed: %v", err)
+		return
+	}
+
+	var info *api.VolumeInfoResponse
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		volume, err := NewVolumeEntryFromId(tx, id)
+		if err == ErrNotFound || (err == nil && !volume.Visible()) {
+			// treat an invisible entry like it doesn't exist
+			http.Error(w, "Id not found", http.StatusNotFound)
+			return ErrNotFound
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		if msg.Name != "" && msg.Name != volume.Info.Name {
+			cluster, err := NewClusterEntryFromId(tx, volume.Info.Cluster)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+			found, err := volumeNameExistsInCluster(tx, cluster, msg.Name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+			if found {
+				http.Error(w, fmt.Sprintf("Name %v already in use in cluster %v",
+					msg.Name, cluster.Info.Id), http.StatusConflict)
+				return ErrConflict
+			}
+			err = fmt.Errorf("Volume %v cannot be renamed, gluster does not support renaming volumes", id)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+
+		if msg.Tags != nil {
+			volume.Info.Tags = nil
+			if len(msg.Tags) != 0 {
+				volume.Info.Tags = msg.Tags
+			}
+			err = volume.Save(tx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return err
+			}
+		}
+
+		info, err = volume.NewInfoResponse(tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
+
 func (a *App) VolumeExpand(w http.ResponseWriter, r *http.Request) {
 	logger.Debug("In VolumeExpand")
 
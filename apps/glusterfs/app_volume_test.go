@@ -27,6 +27,7 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/mux"
 	client "github.com/heketi/heketi/client/api/go-client"
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/pkg/db"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/utils"
@@ -669,6 +670,265 @@ func TestVolumeInfo(t *testing.T) {
 	}
 }
 
+func TestVolumeBrickSets(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Setup database
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		4,    // nodes_per_cluster
+		4,    // devices_per_node,
+		5*TB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	// Unknown volume
+	r, err := http.Get(ts.URL + "/volumes/123/bricksets")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	// Create a volume of two replica sets
+	req := &api.VolumeCreateRequest{}
+	req.Size = 200
+	req.Durability.Type = api.DurabilityReplicate
+	req.Durability.Replicate.Replica = 3
+	v := NewVolumeEntryFromRequest(req)
+	tests.Assert(t, v != nil)
+	err = v.Create(app.db, app.executor, app.Allocator())
+	tests.Assert(t, err == nil, err)
+	app.xo.MockVolumeInfo = func(host string, volume string) (*executors.Volume, error) {
+		return mockVolumeInfoFromDb(app.db, volume)
+	}
+
+	r, err = http.Get(ts.URL + "/volumes/" + v.Info.Id + "/bricksets")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+	var msg api.VolumeBrickSetsResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil, err)
+
+	// Every brick of the volume is in exactly one set
+	tests.Assert(t, len(msg.Sets)*3 == len(v.Bricks), msg.Sets)
+	seen := make(map[string]bool)
+	for _, set := range msg.Sets {
+		tests.Assert(t, len(set) == 3, set)
+		for _, id := range set {
+			tests.Assert(t, utils.SortedStringHas(v.Bricks, id), id)
+			tests.Assert(t, !seen[id], id)
+			seen[id] = true
+		}
+	}
+
+	// Gluster bricks that heketi does not know about
+	app.xo.MockVolumeInfo = func(host string, volume string) (*executors.Volume, error) {
+		return &executors.Volume{
+			Bricks: executors.Bricks{
+				BrickList: []executors.Brick{
+					executors.Brick{Name: "unknown:/a"},
+					executors.Brick{Name: "unknown:/b"},
+					executors.Brick{Name: "unknown:/c"},
+				},
+			},
+		}, nil
+	}
+	r, err = http.Get(ts.URL + "/volumes/" + v.Info.Id + "/bricksets")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusInternalServerError, r.StatusCode)
+}
+
+func TestVolumeUpdate(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Setup database
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		3,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	volumes := []*VolumeEntry{}
+	for i := 0; i < 2; i++ {
+		req := &api.VolumeCreateRequest{}
+		req.Size = 10
+		req.Durability.Type = api.DurabilityDistributeOnly
+		v := NewVolumeEntryFromRequest(req)
+		err = v.Create(app.db, app.executor, app.Allocator())
+		tests.Assert(t, err == nil, err)
+		volumes = append(volumes, v)
+	}
+	v := volumes[0]
+	update := func(id, body string) *http.Response {
+		r, err := http.Post(ts.URL+"/volumes/"+id+"/update",
+			"application/json", bytes.NewBufferString(body))
+		tests.Assert(t, err == nil, err)
+		return r
+	}
+
+	// Unknown volume
+	r := update("123", `{"tags":{"owner":"team-a"}}`)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound, r.StatusCode)
+
+	// Set the tags, the volume is returned
+	r = update(v.Info.Id, `{"tags":{"owner":"team-a"}}`)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+	var info api.VolumeInfoResponse
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Id == v.Info.Id, info.Id)
+	tests.Assert(t, info.Tags["owner"] == "team-a", info.Tags)
+
+	// The tags are kept and reported with the volume
+	r, err = http.Get(ts.URL + "/volumes/" + v.Info.Id)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+	info = api.VolumeInfoResponse{}
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Tags["owner"] == "team-a", info.Tags)
+
+	// Invalid tags
+	r = update(v.Info.Id, `{"tags":{"bad key":"x"}}`)
+	tests.Assert(t, r.StatusCode == http.StatusBadRequest, r.StatusCode)
+	r = update(v.Info.Id, `{"tags":`)
+	tests.Assert(t, r.StatusCode == 422, r.StatusCode)
+
+	// The current name is accepted and leaves the tags alone
+	r = update(v.Info.Id, `{"name":"`+v.Info.Name+`"}`)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+	info = api.VolumeInfoResponse{}
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Tags["owner"] == "team-a", info.Tags)
+
+	// Names of other volumes conflict, and gluster cannot rename
+	r = update(v.Info.Id, `{"name":"`+volumes[1].Info.Name+`"}`)
+	tests.Assert(t, r.StatusCode == http.StatusConflict, r.StatusCode)
+	r = update(v.Info.Id, `{"name":"billing"}`)
+	tests.Assert(t, r.StatusCode == http.StatusBadRequest, r.StatusCode)
+
+	// An empty map removes the tags
+	r = update(v.Info.Id, `{"tags":{}}`)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+	info = api.VolumeInfoResponse{}
+	err = utils.GetJsonFromResponse(r, &info)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(info.Tags) == 0, info.Tags)
+}
+
+func TestVolumeBrickStatus(t *testing.T) {
+	tmpfile := tests.Tempfile()
+	defer os.Remove(tmpfile)
+
+	// Create the app
+	app := NewTestApp(tmpfile)
+	defer app.Close()
+	router := mux.NewRouter()
+	app.SetRoutes(router)
+
+	// Setup the server
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// Setup database
+	err := setupSampleDbWithTopology(app,
+		1,    // clusters
+		3,    // nodes_per_cluster
+		2,    // devices_per_node,
+		5*TB, // disksize)
+	)
+	tests.Assert(t, err == nil)
+
+	// Unknown volume
+	r, err := http.Get(ts.URL + "/volumes/123/bricks")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusNotFound)
+
+	req := &api.VolumeCreateRequest{}
+	req.Size = 100
+	req.Durability.Type = api.DurabilityReplicate
+	req.Durability.Replicate.Replica = 3
+	v := NewVolumeEntryFromRequest(req)
+	tests.Assert(t, v != nil)
+	err = v.Create(app.db, app.executor, app.Allocator())
+	tests.Assert(t, err == nil, err)
+
+	// Gluster lists every brick but the first one as online, along
+	// with a daemon of the volume
+	app.xo.MockVolumeStatus = func(host string, volume string) (*executors.VolumeStatus, error) {
+		vinfo, err := mockVolumeInfoFromDb(app.db, volume)
+		if err != nil {
+			return nil, err
+		}
+		vstatus := &executors.VolumeStatus{VolumeName: volume}
+		for i, brick := range vinfo.Bricks.BrickList {
+			parts := strings.SplitN(brick.Name, ":", 2)
+			status := 1
+			if i == 0 {
+				status = 0
+			}
+			vstatus.Nodes = append(vstatus.Nodes, executors.BrickProcessStatus{
+				Hostname: parts[0],
+				Path:     parts[1],
+				Status:   status,
+			})
+		}
+		vstatus.Nodes = append(vstatus.Nodes, executors.BrickProcessStatus{
+			Hostname: "Self-heal Daemon",
+			Path:     "localhost",
+			Status:   1,
+		})
+		return vstatus, nil
+	}
+
+	r, err = http.Get(ts.URL + "/volumes/" + v.Info.Id + "/bricks")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusOK, r.StatusCode)
+	var msg api.VolumeBrickStatusResponse
+	err = utils.GetJsonFromResponse(r, &msg)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(msg.Bricks) == len(v.Bricks), msg.Bricks)
+	offline := 0
+	for _, brick := range msg.Bricks {
+		tests.Assert(t, utils.SortedStringHas(v.Bricks, brick.Id), brick.Id)
+		if !brick.Online {
+			offline++
+		}
+	}
+	tests.Assert(t, offline == 1, msg.Bricks)
+
+	// Gluster cannot report the status
+	app.xo.MockVolumeStatus = func(host string, volume string) (*executors.VolumeStatus, error) {
+		return nil, fmt.Errorf("Volume %v is not started", volume)
+	}
+	r, err = http.Get(ts.URL + "/volumes/" + v.Info.Id + "/bricks")
+	tests.Assert(t, err == nil)
+	tests.Assert(t, r.StatusCode == http.StatusInternalServerError, r.StatusCode)
+}
+
 func TestVolumeListEmpty(t *testing.T) {
 	tmpfile := tests.Tempfile()
 	defer os.Remove(tmpfile)
@@ -167,6 +167,45 @@ func (c *ClusterEntry) NodeDelete(id string) {
 	c.Info.Nodes = utils.SortedStringsDelete(c.Info.Nodes, id)
 }
 
+func (c *ClusterEntry) SetFlagsConflictString() string {
+	return fmt.Sprintf("Unable to change the flags of cluster [%v] because "+
+		"it contains volumes of a type the new flags do not allow", c.Info.Id)
+}
+
+// Check that the cluster does not hold volumes of a type the new flags
+// no longer allow. Block volumes live on block hosting volumes, so
+// disabling file volumes is only refused for volumes which do not host
+// block volumes.
+func (c *ClusterEntry) CheckSetFlags(tx *bolt.Tx, flags api.ClusterFlags) error {
+	godbc.Require(tx != nil)
+
+	if c.Info.Block && !flags.Block && len(c.Info.BlockVolumes) > 0 {
+		logger.Warning("Cluster [%v] still has %v block volumes",
+			c.Info.Id, len(c.Info.BlockVolumes))
+		return ErrConflict
+	}
+
+	if c.Info.File && !flags.File {
+		files := 0
+		for _, id := range c.Info.Volumes {
+			volume, err := NewVolumeEntryFromId(tx, id)
+			if err != nil {
+				return err
+			}
+			if !volume.Info.Block {
+				files++
+			}
+		}
+		if files > 0 {
+			logger.Warning("Cluster [%v] still has %v file volumes",
+				c.Info.Id, files)
+			return ErrConflict
+		}
+	}
+
+	return nil
+}
+
 func ClusterEntryUpgrade(tx *bolt.Tx) error {
 	err := addBlockFileFlagsInClusterEntry(tx)
 	if err != nil {
@@ -34,6 +34,7 @@ type DeviceEntry struct {
 	Bricks     sort.StringSlice
 	NodeId     string
 	ExtentSize uint64
+	Tags       map[string]string
 }
 
 func DeviceList(tx *bolt.Tx) ([]string, error) {
@@ -275,6 +276,7 @@ func (d *DeviceEntry) NewInfoResponse(tx *bolt.Tx) (*api.DeviceInfoResponse, err
 	info.Name = d.Info.Name
 	info.Storage = d.Info.Storage
 	info.State = d.State
+	info.Tags = d.Tags
 	info.Bricks = make([]api.BrickInfo, 0)
 
 	// Add each drive information
@@ -28,6 +28,7 @@ type NodeEntry struct {
 
 	Info    api.NodeInfo
 	Devices sort.StringSlice
+	Tags    map[string]string
 }
 
 func NewNodeEntry() *NodeEntry {
@@ -391,6 +392,7 @@ func (n *NodeEntry) NewInfoReponse(tx *bolt.Tx) (*api.NodeInfoResponse, error) {
 	info.Id = n.Info.Id
 	info.Zone = n.Info.Zone
 	info.State = n.State
+	info.Tags = n.Tags
 	info.DevicesInfo = make([]api.DeviceInfoResponse, 0)
 
 	// Add each drive information
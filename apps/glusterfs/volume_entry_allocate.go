@@ -143,6 +143,82 @@ func (v *VolumeEntry) getBrickSetForBrickId(db wdb.DB,
 	return setlist, nil
 }
 
+// brickSets returns the brick ids of every set of the volume, in the
+// order gluster reports the bricks
+func (v *VolumeEntry) brickSets(db wdb.RODB,
+	executor executors.Executor, node string) ([][]string, error) {
+
+	vinfo, err := executor.VolumeInfo(node, v.Info.Name)
+	if err != nil {
+		logger.LogError("Unable to get volume info from gluster node %v for volume %v: %v", node, v.Info.Name, err)
+		return nil, err
+	}
+
+	setsize := v.Durability.BricksInSet()
+	if len(vinfo.Bricks.BrickList)%setsize != 0 {
+		return nil, logger.LogError("Volume %v has %v bricks in gluster, not a multiple of %v",
+			v.Info.Name, len(vinfo.Bricks.BrickList), setsize)
+	}
+
+	sets := make([][]string, 0, len(vinfo.Bricks.BrickList)/setsize)
+	for start := 0; start < len(vinfo.Bricks.BrickList); start += setsize {
+		set := make([]string, 0, setsize)
+		for _, brick := range vinfo.Bricks.BrickList[start : start+setsize] {
+			brickentry, err := v.getBrickEntryfromBrickName(db, brick.Name)
+			if err != nil {
+				logger.LogError("Unable to create brick entry using brick name:%v, error: %v", brick.Name, err)
+				return nil, err
+			}
+			set = append(set, brickentry.Id())
+		}
+		sets = append(sets, set)
+	}
+
+	return sets, nil
+}
+
+// brickStatus returns whether the process of each brick of the volume
+// is online. Bricks gluster does not list are reported offline.
+func (v *VolumeEntry) brickStatus(db wdb.RODB,
+	executor executors.Executor, node string) ([]api.BrickStatus, error) {
+
+	vstatus, err := executor.VolumeStatus(node, v.Info.Name)
+	if err != nil {
+		logger.LogError("Unable to get volume status from gluster node %v for volume %v: %v", node, v.Info.Name, err)
+		return nil, err
+	}
+	online := make(map[string]bool)
+	for _, brick := range vstatus.Nodes {
+		online[fmt.Sprintf("%v:%v", brick.Hostname, brick.Path)] = brick.Online()
+	}
+
+	status := make([]api.BrickStatus, 0, len(v.Bricks))
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, brickid := range v.BricksIds() {
+			brickEntry, err := NewBrickEntryFromId(tx, brickid)
+			if err != nil {
+				return err
+			}
+			nodeEntry, err := NewNodeEntryFromId(tx, brickEntry.Info.NodeId)
+			if err != nil {
+				return err
+			}
+			name := fmt.Sprintf("%v:%v",
+				nodeEntry.Info.Hostnames.Storage[0], brickEntry.Info.Path)
+			status = append(status, api.BrickStatus{
+				Id:     brickid,
+				Online: online[name],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
 // canReplaceBrickInBrickSet
 // check if a BrickSet is in a state where it's possible
 // to replace a given one of its bricks:
@@ -0,0 +1,127 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+var (
+	// Returned by VerifyAuth when the server accepts requests without
+	// a token
+	ErrAuthDisabled = errors.New("Authentication is disabled on the server")
+)
+
+// Returned by VerifyAuth when the server rejects the token of the
+// client, normally because the user or key is wrong. Reason is the
+// message of the server.
+type AuthError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *AuthError) Error() string {
+	return "Server rejected the credentials: " + e.Reason
+}
+
+// Messages of the server for tokens which are not valid at its time
+var tokenTimeErrors = []string{
+	"Token is expired",
+	"Token used before issued",
+	"Token is not valid yet",
+}
+
+// VerifyAuth checks that the server accepts the credentials of the
+// client, without changing anything on the server. It returns nil if
+// they are accepted, ErrAuthDisabled if the server does not check them,
+// a ClockSkewError if the token was rejected as expired or not yet
+// valid and the Date of the response shows that the clocks of the
+// client and the server differ, and an AuthError if the credentials
+// were rejected for any other reason.
+func (c *Client) VerifyAuth() error {
+	// Servers which do not check tokens answer requests without one
+	r, localTime, err := c.authCheck(false)
+	if err != nil {
+		return err
+	}
+	r.Body.Close()
+	if r.StatusCode == http.StatusOK {
+		return ErrAuthDisabled
+	}
+
+	r, localTime, err = c.authCheck(true)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusOK {
+		return nil
+	}
+	if r.StatusCode != http.StatusUnauthorized &&
+		r.StatusCode != http.StatusBadRequest {
+		return responseError(r)
+	}
+
+	reason := utils.GetErrorFromResponse(r).Error()
+	for _, msg := range tokenTimeErrors {
+		if !strings.Contains(reason, msg) {
+			continue
+		}
+		serverTime, err := http.ParseTime(r.Header.Get("Date"))
+		if err != nil {
+			break
+		}
+		skew := localTime.Truncate(time.Second).Sub(serverTime)
+		if skew < 0 {
+			skew = -skew
+		}
+		if skew < time.Second {
+			// The clocks agree, so the token itself is out of date
+			break
+		}
+		return &ClockSkewError{
+			ServerTime: serverTime,
+			LocalTime:  localTime,
+			Skew:       skew,
+		}
+	}
+	return &AuthError{StatusCode: r.StatusCode, Reason: reason}
+}
+
+// Send a request which every user may make, with or without a token.
+// Returns the response and the local time it was received at.
+func (c *Client) authCheck(withToken bool) (*http.Response, time.Time, error) {
+	req, err := http.NewRequest("GET", c.host+"/volumes", nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+
+	if withToken {
+		err = c.setToken(req)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	r, err := c.do(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return r, time.Now(), nil
+}
@@ -15,8 +15,6 @@ package client
 import (
 	"io"
 	"net/http"
-
-	"github.com/heketi/heketi/pkg/utils"
 )
 
 func (c *Client) BackupDb(w io.Writer) error {
@@ -39,7 +37,7 @@ func (c *Client) BackupDb(w io.Writer) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Read data from response
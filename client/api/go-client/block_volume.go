@@ -49,7 +49,7 @@ func (c *Client) BlockVolumeCreate(request *api.BlockVolumeCreateRequest) (
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	r, err = c.waitForResponseWithTimer(r, time.Second)
@@ -57,7 +57,7 @@ func (c *Client) BlockVolumeCreate(request *api.BlockVolumeCreateRequest) (
 		return nil, err
 	}
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	var blockvolume api.BlockVolumeInfoResponse
@@ -87,7 +87,7 @@ func (c *Client) BlockVolumeList() (*api.BlockVolumeListResponse, error) {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	var blockvolumes api.BlockVolumeListResponse
@@ -116,7 +116,7 @@ func (c *Client) BlockVolumeInfo(id string) (*api.BlockVolumeInfoResponse, error
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	var blockvolume api.BlockVolumeInfoResponse
@@ -145,7 +145,7 @@ func (c *Client) BlockVolumeDelete(id string) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	r, err = c.waitForResponseWithTimer(r, time.Second)
@@ -153,7 +153,7 @@ func (c *Client) BlockVolumeDelete(id string) error {
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
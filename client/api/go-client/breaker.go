@@ -0,0 +1,140 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// Returned without contacting the server while the circuit is open
+	ErrCircuitOpen = errors.New("Circuit breaker is open: " +
+		"too many consecutive failures contacting the server")
+)
+
+type BreakerState int
+
+const (
+	// Requests are sent normally
+	BreakerClosed BreakerState = iota
+	// Requests fail fast until the cooldown has passed
+	BreakerOpen
+	// A single trial request is allowed to probe the server
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Circuit breaker which opens after threshold consecutive failures.
+// After cooldown one trial request is let through; if it succeeds the
+// circuit closes, otherwise it opens again for another cooldown.
+type circuitBreaker struct {
+	lock      sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Returns ErrCircuitOpen if the request must not be sent
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return nil
+	case BreakerHalfOpen:
+		// Only one trial request at a time
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+
+	return nil
+}
+
+// Record the outcome of a request which was allowed through
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.trial = false
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		b.trial = false
+	}
+}
+
+func (b *circuitBreaker) getState() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+// Returns the state of the client circuit breaker. A client without a
+// circuit breaker is always closed.
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.getState()
+}
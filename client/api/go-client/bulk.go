@@ -0,0 +1,95 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DEFAULT_BULK_CONCURRENCY = 8
+)
+
+// BulkError is returned by calls which act on many items when one or
+// more of the items failed. It maps the id of each failed item to its
+// error. Results for the items which succeeded are still returned.
+type BulkError map[string]error
+
+func (e BulkError) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msgs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", id, e[id]))
+	}
+	return fmt.Sprintf("%v of the requested items failed: %v",
+		len(e), strings.Join(msgs, "; "))
+}
+
+// Call fn for every index in [0, n) using at most concurrency goroutines.
+// All requests still go through the client throttle.
+func forEachConcurrent(n, concurrency int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_BULK_CONCURRENCY
+	}
+
+	var wg sync.WaitGroup
+	sema := make(chan bool, concurrency)
+	for i := 0; i < n; i++ {
+		sema <- true
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sema
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Like forEachConcurrent, but for calls where any failure fails the
+// whole request. Items not yet started when an error occurs are skipped
+// and the first error is returned.
+func forEachConcurrentErr(n, concurrency int, fn func(i int) error) error {
+	var (
+		lock     sync.Mutex
+		firstErr error
+	)
+	forEachConcurrent(n, concurrency, func(i int) {
+		lock.Lock()
+		failed := firstErr != nil
+		lock.Unlock()
+		if failed {
+			return
+		}
+
+		if err := fn(i); err != nil {
+			lock.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			lock.Unlock()
+		}
+	})
+
+	return firstErr
+}
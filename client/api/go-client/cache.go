@@ -0,0 +1,281 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of responses kept if ClientOptions.CacheSize is not set
+	DEFAULT_CACHE_SIZE = 256
+)
+
+// A cached response is never changed once stored, so it can be read
+// without holding the lock of the cache. Revalidating it stores a copy.
+type cachedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	etag       string
+	expires    time.Time
+}
+
+// Cache of successful GET responses, keyed by URL. Stale responses with
+// an ETag are revalidated with If-None-Match instead of fetched again.
+// Any successful write to a path drops the cached responses of that
+// path, of the paths below it and of the paths above it, so that both
+// the resource and the lists which include it are read again, along with
+// the entries which list the written one (see cacheDependents). Writes
+// which the server runs in the background drop them again once their
+// operation is seen to finish, as reads made while it ran may have
+// cached a partial change.
+type responseCache struct {
+	ttl  time.Duration
+	size int
+
+	lock    sync.Mutex
+	entries map[string]*cachedResponse
+
+	// Paths written by operations still running, by status location
+	pending map[string]string
+}
+
+// Entries whose responses include entries of another kind: clusters
+// list their nodes and volumes, and nodes their devices. The client does
+// not know which cluster or node owns an entry without asking the
+// server, so a write to an entry drops all the cached clusters or nodes.
+var cacheDependents = map[string][]string{
+	"nodes":        {"/clusters"},
+	"devices":      {"/nodes"},
+	"volumes":      {"/clusters"},
+	"blockvolumes": {"/clusters", "/volumes"},
+}
+
+func newResponseCache(ttl time.Duration, size int) *responseCache {
+	if size <= 0 {
+		size = DEFAULT_CACHE_SIZE
+	}
+	return &responseCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*cachedResponse),
+		pending: make(map[string]string),
+	}
+}
+
+func (rc *responseCache) do(req *http.Request,
+	send RoundTripFunc) (*http.Response, error) {
+
+	// The status of asynchronous operations changes on every poll
+	if strings.HasPrefix(req.URL.Path, ASYNC_ROUTE+"/") {
+		r, err := send(req)
+		if err == nil && r.Header.Get("X-Pending") != "true" {
+			rc.finished(req.URL.Path)
+		}
+		return r, err
+	}
+
+	if req.Method != "GET" {
+		r, err := send(req)
+		if err == nil && r.StatusCode < http.StatusBadRequest {
+			rc.invalidate(req.URL.Path)
+			if r.StatusCode == http.StatusAccepted {
+				rc.started(r.Header.Get("Location"), req.URL.Path)
+			}
+		}
+		return r, err
+	}
+
+	// Requests may ask for a response from the server
+	if strings.Contains(req.Header.Get("Cache-Control"), "no-cache") {
+		return send(req)
+	}
+
+	key := req.URL.String()
+	rc.lock.Lock()
+	entry := rc.entries[key]
+	rc.lock.Unlock()
+	if entry != nil {
+		if time.Now().Before(entry.expires) {
+			return entry.response(req), nil
+		}
+		if entry.etag != "" && req.Header.Get("If-None-Match") == "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+	}
+
+	r, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.StatusCode == http.StatusNotModified && entry != nil {
+		r.Body.Close()
+		revalidated := *entry
+		revalidated.expires = time.Now().Add(rc.lifetime(r))
+		rc.lock.Lock()
+		// Unless a write dropped it in the meantime
+		if rc.entries[key] == entry {
+			rc.entries[key] = &revalidated
+		}
+		rc.lock.Unlock()
+		return revalidated.response(req), nil
+	}
+
+	ttl := rc.lifetime(r)
+	if r.StatusCode != http.StatusOK || r.Header.Get("X-Pending") != "" || ttl <= 0 {
+		return r, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	entry = &cachedResponse{
+		statusCode: r.StatusCode,
+		header:     r.Header,
+		body:       body,
+		etag:       r.Header.Get("ETag"),
+		expires:    time.Now().Add(ttl),
+	}
+	rc.store(key, entry)
+
+	return entry.response(req), nil
+}
+
+// Time a response may be reused, limited by its Cache-Control header
+func (rc *responseCache) lifetime(r *http.Response) time.Duration {
+	ttl := rc.ttl
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && time.Duration(seconds)*time.Second < ttl {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return ttl
+}
+
+func (rc *responseCache) store(key string, entry *cachedResponse) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	// Make room by dropping the response closest to expiring
+	if _, ok := rc.entries[key]; !ok && len(rc.entries) >= rc.size {
+		var oldest string
+		for k, e := range rc.entries {
+			if oldest == "" || e.expires.Before(rc.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		delete(rc.entries, oldest)
+	}
+	rc.entries[key] = entry
+}
+
+// Remember the path written by an operation running in the background
+func (rc *responseCache) started(location, path string) {
+	u, err := url.Parse(location)
+	if err != nil || u.Path == "" {
+		return
+	}
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	// Operations which are never waited for are not kept forever
+	if len(rc.pending) >= rc.size {
+		for key := range rc.pending {
+			delete(rc.pending, key)
+			break
+		}
+	}
+	rc.pending[u.Path] = path
+}
+
+// Drop the reads of the path written by a finished operation
+func (rc *responseCache) finished(location string) {
+	rc.lock.Lock()
+	path, ok := rc.pending[location]
+	delete(rc.pending, location)
+	rc.lock.Unlock()
+
+	if ok {
+		rc.invalidate(path)
+	}
+}
+
+func (rc *responseCache) invalidate(path string) {
+	path = strings.TrimSuffix(path, "/")
+	related := cacheDependents[strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]]
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	for key := range rc.entries {
+		req, err := http.NewRequest("GET", key, nil)
+		if err != nil {
+			delete(rc.entries, key)
+			continue
+		}
+		cached := strings.TrimSuffix(req.URL.Path, "/")
+		if cached == path ||
+			strings.HasPrefix(cached, path+"/") ||
+			strings.HasPrefix(path, cached+"/") {
+			delete(rc.entries, key)
+			continue
+		}
+		for _, prefix := range related {
+			if cached == prefix || strings.HasPrefix(cached, prefix+"/") {
+				delete(rc.entries, key)
+				break
+			}
+		}
+	}
+}
+
+func (e *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.statusCode) + " " + http.StatusText(e.statusCode),
+		StatusCode:    e.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cloneHeader(e.header),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
@@ -0,0 +1,133 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// Storage capacity of a cluster. Sizes are in bytes.
+type ClusterCapacity struct {
+	ClusterId string
+	Total     uint64
+	Used      uint64
+
+	// Free space on devices which can currently be used, that is online
+	// devices on online nodes
+	Free uint64
+
+	// Largest free space on a single usable device. A brick cannot span
+	// devices, so each brick of a new volume must fit in this.
+	LargestFree uint64
+}
+
+// Storage capacity of every cluster and of all of them together
+type CapacityReport struct {
+	Clusters []ClusterCapacity
+	Total    uint64
+	Used     uint64
+	Free     uint64
+}
+
+// CapacityInfo adds up the storage of the devices of each cluster. The
+// clusters, nodes and devices are read with at most
+// DEFAULT_BULK_CONCURRENCY requests in flight. Callers which ask
+// repeatedly should enable the response cache (ClientOptions.CacheTTL),
+// which keeps the reads and drops those a write changes.
+func (c *Client) CapacityInfo() (*CapacityReport, error) {
+	clusterlist, err := c.ClusterList()
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]*api.ClusterInfoResponse, len(clusterlist.Clusters))
+	err = forEachConcurrentErr(len(clusters), DEFAULT_BULK_CONCURRENCY,
+		func(i int) (err error) {
+			clusters[i], err = c.ClusterInfo(clusterlist.Clusters[i])
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the nodes of all the clusters together
+	type nodeItem struct {
+		cluster int
+		id      string
+	}
+	nodeItems := []nodeItem{}
+	for i, cluster := range clusters {
+		for _, id := range cluster.Nodes {
+			nodeItems = append(nodeItems, nodeItem{cluster: i, id: id})
+		}
+	}
+	nodes := make([]*api.NodeInfoResponse, len(nodeItems))
+	err = forEachConcurrentErr(len(nodes), DEFAULT_BULK_CONCURRENCY,
+		func(i int) (err error) {
+			nodes[i], err = c.NodeInfo(nodeItems[i].id)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// Then the devices of all the nodes
+	type deviceItem struct {
+		node int
+		id   string
+	}
+	deviceItems := []deviceItem{}
+	for i, node := range nodes {
+		for _, device := range node.DevicesInfo {
+			deviceItems = append(deviceItems, deviceItem{node: i, id: device.Id})
+		}
+	}
+	devices := make([]*api.DeviceInfoResponse, len(deviceItems))
+	err = forEachConcurrentErr(len(devices), DEFAULT_BULK_CONCURRENCY,
+		func(i int) (err error) {
+			devices[i], err = c.DeviceInfo(deviceItems[i].id)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CapacityReport{
+		Clusters: make([]ClusterCapacity, len(clusters)),
+	}
+	for i, cluster := range clusters {
+		report.Clusters[i].ClusterId = cluster.Id
+	}
+	for i, device := range devices {
+		node := nodes[deviceItems[i].node]
+		capacity := &report.Clusters[nodeItems[deviceItems[i].node].cluster]
+		capacity.Total += device.Storage.Total * 1024
+		capacity.Used += device.Storage.Used * 1024
+		if node.State != api.EntryStateOnline ||
+			device.State != api.EntryStateOnline {
+			continue
+		}
+		free := device.Storage.Free * 1024
+		capacity.Free += free
+		if free > capacity.LargestFree {
+			capacity.LargestFree = free
+		}
+	}
+	for _, capacity := range report.Clusters {
+		report.Total += capacity.Total
+		report.Used += capacity.Used
+		report.Free += capacity.Free
+	}
+
+	return report, nil
+}
@@ -13,41 +13,408 @@
 package client
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/heketi/heketi/pkg/utils"
 )
 
 const (
 	MAX_CONCURRENT_REQUESTS = 32
+
+	// Same limit as the http package default
+	DEFAULT_MAX_REDIRECTS = 10
 )
 
+var (
+	// Returned when the server does not provide the requested endpoint
+	ErrNotSupported = errors.New("Operation not supported by server")
+
+	// Returned when an asynchronous operation reports no progress for
+	// longer than ClientOptions.StallTimeout
+	ErrOperationStalled = errors.New("Operation made no progress within the stall timeout")
+
+	// Returned when the body of a response is not read completely
+	// within ClientOptions.BodyReadTimeout
+	ErrBodyReadTimeout = errors.New("Timed out reading the response body")
+
+	// Returned by calls made after the client was closed
+	ErrClientClosed = errors.New("Client is closed")
+)
+
+// Returned when a conditional request is rejected by the server with
+// 412 Precondition Failed because the resource changed after the ETag
+// passed in If-Match was read
+type PreconditionFailedError struct {
+	ETag    string
+	Message string
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("Resource changed since ETag %v was read: %v",
+		e.ETag, e.Message)
+}
+
+// Returned when the Date reported by the server differs from the local
+// clock by more than ClientOptions.MaxClockSkew
+type ClockSkewError struct {
+	ServerTime time.Time
+	LocalTime  time.Time
+	Skew       time.Duration
+}
+
+func (e *ClockSkewError) Error() string {
+	return fmt.Sprintf("Server clock differs from the local clock by %v "+
+		"(server %v, local %v). Authentication tokens are rejected when "+
+		"the clocks are not synchronized",
+		e.Skew, e.ServerTime.UTC().Format(time.RFC3339),
+		e.LocalTime.UTC().Format(time.RFC3339))
+}
+
+// Error for a request which the server answered with a failure. The
+// message is the one sent by the server. RequestID is the id the server
+// assigned to the request (X-Request-ID), which identifies it in the
+// server logs. It is empty if the server did not report one.
+type RequestError struct {
+	StatusCode int
+	RequestID  string
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+// Returned when the node, device or operation asked for does not exist
+type NotFoundError struct {
+	// "node", "device" or "operation"
+	Kind string
+	Id   string
+
+	Err error
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Kind + " " + e.Id + " not found: " + e.Err.Error()
+}
+
+// Return a NotFoundError for a failed read of a missing entry
+func notFound(kind, id string, err error) error {
+	if rerr, ok := err.(*RequestError); ok &&
+		rerr.StatusCode == http.StatusNotFound {
+		return &NotFoundError{Kind: kind, Id: id, Err: err}
+	}
+	return err
+}
+
+// Return the id the server assigned to the request which failed with
+// err, or an empty string if the server did not report one
+func RequestIDFromError(err error) string {
+	if rerr, ok := err.(*RequestError); ok {
+		return rerr.RequestID
+	}
+	return ""
+}
+
+// Headers which are owned by the client and cannot be overridden
+// through ClientOptions.Headers
+var reservedHeaders = []string{
+	"Authorization",
+	"User-Agent",
+}
+
+// Client configuration options
+type ClientOptions struct {
+	// Headers added to every request sent to the server, including
+	// status polls and redirects. Reserved headers (Authorization and
+	// User-Agent) are never taken from here, and any header the client
+	// sets itself for a request (such as Content-Type) takes precedence
+	// over the value given here.
+	Headers http.Header
+
+	// Provides the Authorization header of every request. If not set
+	// the client signs a JWT with the user and key it was created with.
+	TokenProvider TokenProvider
+
+	// Audience (aud) and custom claims added to the JWT the client signs
+	// when TokenProvider is not set. The claims set by the client (iss,
+	// iat, exp, qsh and aud) cannot be given as custom claims; requests
+	// fail if they are.
+	Audience string
+	Claims   map[string]interface{}
+
+	// Lifetime of the JWT the client signs when TokenProvider is not
+	// set, DEFAULT_TOKEN_TTL if not set. It must be between MIN_TOKEN_TTL
+	// and MAX_TOKEN_TTL: NewValidatedClient rejects other values, and
+	// every request of a client created with NewClientWithOptions fails
+	// with the same error.
+	TokenTTL time.Duration
+
+	// Key id (kid) set in the header of the JWT the client signs when
+	// TokenProvider is not set, so that a server holding several keys
+	// during a key rotation knows which one to verify it with. No kid
+	// is set if empty.
+	KeyID string
+
+	// Scheme of the Authorization header when TokenProvider is not set,
+	// DEFAULT_AUTH_SCHEME if not set. Set it to "bearer" for servers or
+	// gateways which only accept the lower case scheme.
+	AuthScheme string
+
+	// Open the circuit breaker after this many consecutive requests
+	// fail with a connection error or a 5xx status. While open, requests
+	// fail with ErrCircuitOpen for BreakerCooldown, after which a single
+	// trial request decides whether to close it again. 0 disables the
+	// circuit breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Called with the status location of every asynchronous operation
+	// the server accepts, before the client starts waiting for it. A
+	// caller which must survive a restart while an operation is in
+	// progress persists the location here and passes it to
+	// WaitForOperation after restarting, instead of issuing the request
+	// again. The location is an absolute URL. The callback must not block.
+	OperationStarted func(method, path, location string)
+
+	// If set, the Date header of every response is compared with the
+	// local clock and the request fails with a ClockSkewError when they
+	// differ by more than this. Tokens signed by the client expire
+	// after TokenTTL, so servers whose clock is off by more than that
+	// reject every request. 0 disables the check.
+	MaxClockSkew time.Duration
+
+	// If set, waiting for an asynchronous operation fails with
+	// ErrOperationStalled when the value of the X-Progress header on its
+	// pending status responses does not change for this long. The
+	// operation may take as long as it needs while the value keeps
+	// changing. Servers which do not send X-Progress are considered
+	// stalled once this much time has passed, so only set this when
+	// the server reports progress. 0 disables the check.
+	StallTimeout time.Duration
+
+	// If set, every request asks for this version of the API with an
+	// Accept: application/vnd.heketi.v<N>+json header, unless the request
+	// sets its own Accept header. Call CheckAPIVersion after creating the
+	// client to fail early when the server does not support the version.
+	// 0 sends no Accept header, which is the previous behavior.
+	APIVersion int
+
+	// Called for every response received, successful or not, with the
+	// id the server assigned to the request (X-Request-ID), which may be
+	// empty for servers that do not assign ids. The callback must not
+	// block.
+	RequestCompleted func(method, path, requestID string, statusCode int)
+
+	// If set, the body of every response must be read completely within
+	// this time after its headers were received, otherwise reading it
+	// fails with ErrBodyReadTimeout. This protects against servers or
+	// proxies which send the headers and then stall. 0 disables the
+	// limit.
+	BodyReadTimeout time.Duration
+
+	// If set, the client records spans for its requests and for waits
+	// on asynchronous operations, see Tracer. Nothing is recorded if
+	// not set.
+	Tracer Tracer
+
+	// If set, successful GET responses are reused for up to this long,
+	// or less if the server limits it with Cache-Control. Expired
+	// responses with an ETag are revalidated instead of fetched again.
+	// A successful write to a resource drops the cached reads of it, of
+	// the lists which include it and of the clusters or nodes which own
+	// it, and again when the server finishes a write it accepted to run
+	// in the background. Requests with a Cache-Control: no-cache header,
+	// which an Interceptor may add, always go to the server. CacheSize
+	// limits the number of responses kept, DEFAULT_CACHE_SIZE if not
+	// set. 0 disables caching.
+	CacheTTL  time.Duration
+	CacheSize int
+
+	// Maximum number of redirects followed for a single request,
+	// DEFAULT_MAX_REDIRECTS if not set. 0 disallows redirects.
+	// Asynchronous operations are completed with a 303 redirect to the
+	// new resource, so disallowing redirects makes calls which create
+	// resources fail.
+	MaxRedirects *int
+
+	// If set, the status polls of asynchronous operations get their own
+	// pool of this many concurrent requests instead of sharing the
+	// MAX_CONCURRENT_REQUESTS of the client with all other requests, so
+	// that waiting on many operations does not hold up starting new
+	// ones. 0 shares the pool.
+	MaxPollRequests int
+
+	// Called around every request, including status polls and reads of
+	// the response cache, see Interceptor. The first interceptor is the
+	// outermost one. After all of them come the response cache and
+	// tracing, and then the send of the request, which is throttled,
+	// guarded by the circuit breaker and checked for clock skew. The
+	// token is set before the chain and not by it.
+	Interceptors []Interceptor
+}
+
+// Validate checks the options for values the client cannot work with.
+// NewValidatedClient returns its error. NewClientWithOptions checks the
+// options once as well, and every request of the client fails with the
+// error instead of signing a token with invalid settings.
+func (opts *ClientOptions) Validate() error {
+	err := validateTokenTTL(opts.TokenTTL)
+	if err != nil {
+		return err
+	}
+	err = validateAuthScheme(opts.AuthScheme)
+	if err != nil {
+		return err
+	}
+	return validateClaims(opts.Claims)
+}
+
 // Client object
 type Client struct {
 	host     string
 	key      string
 	user     string
 	throttle chan bool
+	opts     ClientOptions
+	tokens   TokenProvider
+	breaker  *circuitBreaker
+
+	maxRedirects int
+
+	// Only set if status polls have their own pool
+	pollThrottle chan bool
+
+	// Connections are kept per client so they can be released by Close
+	transport *http.Transport
+	lock      sync.Mutex
+	closed    bool
+
+	// Every request is cancelled when Close cancels this context
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Only set if caching is enabled
+	cache *responseCache
+
+	// Sends requests through the interceptors
+	roundTrip RoundTripFunc
 }
 
 // Creates a new client to access a Heketi server
 func NewClient(host, user, key string) *Client {
+	return NewClientWithOptions(host, user, key, ClientOptions{})
+}
+
+// Creates a new client to access a Heketi server using the given
+// options, after checking them with ClientOptions.Validate
+func NewValidatedClient(host, user, key string, opts ClientOptions) (*Client, error) {
+	err := opts.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithOptions(host, user, key, opts), nil
+}
+
+// Creates a new client to access a Heketi server using the given options
+func NewClientWithOptions(host, user, key string, opts ClientOptions) *Client {
 	c := &Client{}
 
 	c.key = key
 	c.host = host
 	c.user = user
+	c.opts = opts
+
+	c.tokens = opts.TokenProvider
+	if c.tokens == nil {
+		j := newJwtTokenProvider(user, key, opts.Audience, opts.Claims,
+			opts.TokenTTL)
+		j.keyID = opts.KeyID
+		if opts.AuthScheme != "" {
+			j.scheme = opts.AuthScheme
+		}
+		j.err = opts.Validate()
+		c.tokens = j
+	}
+
+	c.maxRedirects = DEFAULT_MAX_REDIRECTS
+	if opts.MaxRedirects != nil {
+		c.maxRedirects = *opts.MaxRedirects
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	if opts.CacheTTL > 0 {
+		c.cache = newResponseCache(opts.CacheTTL, opts.CacheSize)
+	}
+
+	if opts.BreakerThreshold > 0 {
+		c.breaker = newCircuitBreaker(opts.BreakerThreshold, opts.BreakerCooldown)
+	}
+
+	interceptors := append([]Interceptor{}, opts.Interceptors...)
+	if c.cache != nil {
+		interceptors = append(interceptors, c.cache.do)
+	}
+	if opts.Tracer != nil {
+		interceptors = append(interceptors, c.trace)
+	}
+	c.roundTrip = chainInterceptors(interceptors, c.send)
 
 	// Maximum concurrent requests
 	c.throttle = make(chan bool, MAX_CONCURRENT_REQUESTS)
+	if opts.MaxPollRequests > 0 {
+		c.pollThrottle = make(chan bool, opts.MaxPollRequests)
+	}
+
+	// Same settings as http.DefaultTransport
+	c.transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
 
 	return c
 }
 
+// Close releases the idle connections held by the client. Requests
+// in progress, including waits for asynchronous operations, are
+// cancelled and return ErrClientClosed, as does any call made after
+// Close. Close may be called more than once.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.cancel()
+	c.transport.CloseIdleConnections()
+
+	return nil
+}
+
+func (c *Client) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.closed
+}
+
 // Create a client to access a Heketi server without authentication enabled
 func NewClientNoAuth(host string) *Client {
 	return NewClient(host, "", "")
@@ -74,7 +441,7 @@ func (c *Client) Hello() error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
@@ -82,20 +449,220 @@ func (c *Client) Hello() error {
 
 // Make sure we do not run out of fds by throttling the requests
 func (c *Client) do(req *http.Request) (*http.Response, error) {
-	c.throttle <- true
+	return c.roundTrip(req)
+}
+
+// Record a span for the request
+func (c *Client) trace(req *http.Request,
+	next RoundTripFunc) (*http.Response, error) {
+
+	span := c.opts.Tracer.StartSpan("heketi "+req.Method+" "+req.URL.Path,
+		spanFromContext(req.Context()))
+	span.Inject(req.Header)
+
+	r, err := next(req)
+	if r != nil {
+		span.SetAttribute("http.status_code", strconv.Itoa(r.StatusCode))
+		if id := r.Header.Get("X-Request-ID"); id != "" {
+			span.SetAttribute("heketi.request_id", id)
+		}
+	}
+	span.End(err)
+
+	return r, err
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.isClosed() {
+		return nil, ErrClientClosed
+	}
+	c.setHeaders(req)
+	if c.opts.APIVersion > 0 && req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", apiMediaType(c.opts.APIVersion))
+	}
+
+	throttle := c.throttle
+	if c.pollThrottle != nil && isPoll(req.Context()) {
+		throttle = c.pollThrottle
+	}
+	select {
+	case throttle <- true:
+	case <-c.ctx.Done():
+		return nil, ErrClientClosed
+	}
 	defer func() {
-		<-c.throttle
+		<-throttle
+	}()
+
+	err := c.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	// The request is cancelled by Close, and otherwise lives until its
+	// response body is closed
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-c.ctx.Done():
+		case <-ctx.Done():
+		}
+		cancel()
 	}()
+	req = req.WithContext(ctx)
 
-	httpClient := &http.Client{}
+	httpClient := &http.Client{Transport: c.transport}
 	httpClient.CheckRedirect = c.checkRedirect
-	return httpClient.Do(req)
+	r, err := httpClient.Do(req)
+	if err != nil && c.ctx.Err() != nil {
+		cancel()
+		return nil, ErrClientClosed
+	}
+	c.breaker.record(err == nil && r.StatusCode < http.StatusInternalServerError)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	r.Body = &cancelBody{body: r.Body, cancel: cancel, closed: c.ctx}
+
+	if c.opts.RequestCompleted != nil {
+		c.opts.RequestCompleted(req.Method, req.URL.Path,
+			r.Header.Get("X-Request-ID"), r.StatusCode)
+	}
+
+	err = c.checkClockSkew(r)
+	if err != nil {
+		r.Body.Close()
+		return nil, err
+	}
+
+	if c.opts.BodyReadTimeout > 0 {
+		r.Body = newTimeoutBody(r.Body, c.opts.BodyReadTimeout)
+	}
+
+	return r, nil
+}
+
+// Response body of a request which is cancelled once the body is
+// closed. Reads failing because the client was closed return
+// ErrClientClosed.
+type cancelBody struct {
+	body   io.ReadCloser
+	cancel context.CancelFunc
+	closed context.Context
+}
+
+func (b *cancelBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if err != nil && err != io.EOF && b.closed.Err() != nil {
+		return n, ErrClientClosed
+	}
+	return n, err
+}
+
+func (b *cancelBody) Close() error {
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
+
+// Wait for d to pass. Returns ErrClientClosed if the client is closed
+// first.
+func (c *Client) sleep(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.ctx.Done():
+		return ErrClientClosed
+	}
+}
+
+// Response body which must be read within a deadline. When the deadline
+// passes the underlying body is closed, which aborts any read in
+// progress.
+type timeoutBody struct {
+	body  io.ReadCloser
+	timer *time.Timer
+
+	lock    sync.Mutex
+	expired bool
+}
+
+func newTimeoutBody(body io.ReadCloser, timeout time.Duration) *timeoutBody {
+	t := &timeoutBody{body: body}
+	t.timer = time.AfterFunc(timeout, func() {
+		t.lock.Lock()
+		t.expired = true
+		t.lock.Unlock()
+		body.Close()
+	})
+	return t
+}
+
+func (t *timeoutBody) Read(p []byte) (int, error) {
+	n, err := t.body.Read(p)
+	if err != nil {
+		t.lock.Lock()
+		expired := t.expired
+		t.lock.Unlock()
+		if expired {
+			return n, ErrBodyReadTimeout
+		}
+		if err == io.EOF {
+			t.timer.Stop()
+		}
+	}
+	return n, err
+}
+
+func (t *timeoutBody) Close() error {
+	t.timer.Stop()
+	return t.body.Close()
+}
+
+// Compare the time reported by the server with the local clock.
+// Responses without a valid Date header are not checked.
+func (c *Client) checkClockSkew(r *http.Response) error {
+	if c.opts.MaxClockSkew <= 0 {
+		return nil
+	}
+	serverTime, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return nil
+	}
+
+	// The Date header has a resolution of one second
+	localTime := time.Now()
+	skew := localTime.Truncate(time.Second).Sub(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > c.opts.MaxClockSkew {
+		return &ClockSkewError{
+			ServerTime: serverTime,
+			LocalTime:  localTime,
+			Skew:       skew,
+		}
+	}
+	return nil
 }
 
 // This function is called by the http package if it detects that it needs to
 // be redirected.  This happens when the server returns a 303 HTTP Status.
 // Here we create a new token before it makes the next request.
 func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.maxRedirects <= 0 {
+		return fmt.Errorf("Server redirected %v to %v but redirects are disabled",
+			via[0].URL, req.URL)
+	}
+	if len(via) > c.maxRedirects {
+		return fmt.Errorf("Stopped after %v redirects following %v",
+			c.maxRedirects, via[0].URL)
+	}
+
 	return c.setToken(req)
 }
 
@@ -109,12 +676,52 @@ func (c *Client) waitForResponseWithTimer(r *http.Response,
 		return nil, err
 	}
 
+	if c.opts.OperationStarted != nil {
+		c.opts.OperationStarted(r.Request.Method, r.Request.URL.Path,
+			location.String())
+	}
+
+	return c.pollOperation(location.String(), waitTime)
+}
+
+// Poll the status location of an operation until it is no longer pending
+func (c *Client) pollOperation(location string,
+	waitTime time.Duration) (*http.Response, error) {
+
+	return c.pollOperationUntil(location, waitTime, time.Time{}, nil)
+}
+
+// Like pollOperation, but gives up with ErrWaitTimeout once the deadline
+// has passed. A zero deadline waits forever. If set, pending is called
+// with every pending status response before its body is closed.
+func (c *Client) pollOperationUntil(location string,
+	waitTime time.Duration, deadline time.Time,
+	pending func(r *http.Response)) (*http.Response, error) {
+
+	span := c.startWaitSpan(location)
+	r, err := c.pollOperationSpan(location, waitTime, deadline, pending, span)
+	if span != nil {
+		span.End(err)
+	}
+	return r, err
+}
+
+func (c *Client) pollOperationSpan(location string,
+	waitTime time.Duration, deadline time.Time,
+	pending func(r *http.Response), span Span) (*http.Response, error) {
+
+	progress := ""
+	progressed := time.Now()
 	for {
 		// Create request
-		req, err := http.NewRequest("GET", location.String(), nil)
+		req, err := http.NewRequest("GET", location, nil)
 		if err != nil {
 			return nil, err
 		}
+		req = req.WithContext(contextWithPoll(req.Context()))
+		if span != nil {
+			req = req.WithContext(contextWithSpan(req.Context(), span))
+		}
 
 		// Set token
 		err = c.setToken(req)
@@ -123,7 +730,7 @@ func (c *Client) waitForResponseWithTimer(r *http.Response,
 		}
 
 		// Wait for response
-		r, err = c.do(req)
+		r, err := c.do(req)
 		if err != nil {
 			return nil, err
 		}
@@ -131,9 +738,32 @@ func (c *Client) waitForResponseWithTimer(r *http.Response,
 		// Check if the request is pending
 		if r.Header.Get("X-Pending") == "true" {
 			if r.StatusCode != http.StatusOK {
-				return nil, utils.GetErrorFromResponse(r)
+				return nil, responseError(r)
+			}
+			if pending != nil {
+				pending(r)
+			}
+			r.Body.Close()
+			if p := r.Header.Get("X-Progress"); p != progress {
+				progress = p
+				progressed = time.Now()
+			}
+			if span != nil {
+				span.AddEvent("pending", map[string]string{
+					"heketi.progress": progress,
+				})
+			}
+			if c.opts.StallTimeout > 0 &&
+				time.Since(progressed) > c.opts.StallTimeout {
+				return nil, ErrOperationStalled
+			}
+			if !deadline.IsZero() && time.Now().Add(waitTime).After(deadline) {
+				return nil, ErrWaitTimeout
+			}
+			err = c.sleep(waitTime)
+			if err != nil {
+				return nil, err
 			}
-			time.Sleep(waitTime)
 		} else {
 			return r, nil
 		}
@@ -141,37 +771,93 @@ func (c *Client) waitForResponseWithTimer(r *http.Response,
 
 }
 
-// Create JSON Web Token
-func (c *Client) setToken(r *http.Request) error {
+// Add the configured headers to the request, skipping reserved headers
+// and any header which has already been set on the request
+func (c *Client) setHeaders(req *http.Request) {
+	for key, values := range c.opts.Headers {
+		if isReservedHeader(key) || req.Header.Get(key) != "" {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
+
+func isReservedHeader(key string) bool {
+	for _, reserved := range reservedHeaders {
+		if strings.EqualFold(key, reserved) {
+			return true
+		}
+	}
+	return false
+}
 
-	// Create qsh hash
-	qshstring := r.Method + "&" + r.URL.Path
-	hash := sha256.New()
-	hash.Write([]byte(qshstring))
+// Return the error sent by the server together with the status code
+// and the id the server assigned to the request
+func responseError(r *http.Response) error {
+	return &RequestError{
+		StatusCode: r.StatusCode,
+		RequestID:  r.Header.Get("X-Request-ID"),
+		Err:        utils.GetErrorFromResponse(r),
+	}
+}
 
-	// Create Token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		// Set issuer
-		"iss": c.user,
+type pollKey struct{}
 
-		// Set issued at time
-		"iat": time.Now().Unix(),
+// Mark a request as a status poll of an asynchronous operation
+func contextWithPoll(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pollKey{}, true)
+}
 
-		// Set expiration
-		"exp": time.Now().Add(time.Minute * 5).Unix(),
+func isPoll(ctx context.Context) bool {
+	poll, _ := ctx.Value(pollKey{}).(bool)
+	return poll
+}
 
-		// Set qsh
-		"qsh": hex.EncodeToString(hash.Sum(nil)),
-	})
+// Servers which do not provide an endpoint answer with 404 Not Found
+// for unknown paths or 405 Method Not Allowed for unknown methods.
+// Endpoints which can legitimately return 404 for a missing resource
+// must not use this check.
+func isNotSupported(r *http.Response) bool {
+	return r.StatusCode == http.StatusNotFound ||
+		r.StatusCode == http.StatusMethodNotAllowed
+}
+
+// Set the If-Match header if an ETag was given
+func setIfMatch(req *http.Request, etag string) {
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+}
+
+// Convert a 412 response into a PreconditionFailedError
+func preconditionFailed(r *http.Response, etag string) error {
+	s, err := utils.GetStringFromResponse(r)
+	if err != nil {
+		return err
+	}
+	return &PreconditionFailedError{
+		ETag:    etag,
+		Message: strings.TrimSpace(s),
+	}
+}
+
+// Set the Authorization header using the token provider
+func (c *Client) setToken(r *http.Request) error {
+	// Clients created without credentials talk to servers with
+	// authentication disabled, there is nothing to sign
+	if c.opts.TokenProvider == nil && c.user == "" && c.key == "" {
+		return nil
+	}
 
-	// Sign the token
-	signedtoken, err := token.SignedString([]byte(c.key))
+	token, err := c.tokens.Token(r.Method, r.URL.Path)
 	if err != nil {
 		return err
 	}
 
 	// Save it in the header
-	r.Header.Set("Authorization", "bearer "+signedtoken)
+	r.Header.Set("Authorization", token)
 
 	return nil
 }
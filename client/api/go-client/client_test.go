@@ -13,14 +13,28 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"github.com/heketi/heketi/apps/glusterfs"
+	"github.com/heketi/heketi/client/api/go-client/clienttest"
 	"github.com/heketi/heketi/middleware"
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 	"github.com/heketi/heketi/pkg/utils"
@@ -316,6 +330,9 @@ func TestClientCluster(t *testing.T) {
 	tests.Assert(t, err == nil)
 	tests.Assert(t, info.File == true)
 	tests.Assert(t, info.Block == false)
+	flags, err := c.ClusterFlags(cluster.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, flags.File && !flags.Block, flags)
 
 	// Get a list of clusters
 	list, err := c.ClusterList()
@@ -749,3 +766,2932 @@ func TestLogLevel(t *testing.T) {
 		`expected llinfo.LogLevel["glusterfs"] == "info", get:`, llinfo.LogLevel)
 	return
 }
+
+func TestClientAsyncPending(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	// Delete completes after the operation has been pending twice
+	s.HandleAsync("DELETE", "/volumes/abc", &clienttest.AsyncOperation{
+		Pending: 2,
+	})
+
+	c := NewClient(s.URL(), "admin", TEST_ADMIN_KEY)
+	err := c.VolumeDelete("abc")
+	tests.Assert(t, err == nil, err)
+
+	// One DELETE plus three polls of the status url
+	requests := s.Requests()
+	tests.Assert(t, len(requests) == 4, requests)
+	tests.Assert(t, requests[0].Method == "DELETE")
+	for _, r := range requests {
+		tests.Assert(t, r.Header.Get("Authorization") != "")
+	}
+	for _, r := range requests[1:] {
+		tests.Assert(t, r.Method == "GET")
+		tests.Assert(t, r.Path == requests[1].Path)
+	}
+}
+
+func TestClientAsyncRedirect(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.HandleAsync("POST", "/volumes", &clienttest.AsyncOperation{
+		Location: "/volumes/abc",
+	})
+	s.Handle("GET", "/volumes/abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprint(w, `{"id":"abc","size":10}`)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	volume, err := c.VolumeCreate(&api.VolumeCreateRequest{Size: 10})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, volume.Id == "abc")
+	tests.Assert(t, volume.Size == 10)
+
+	requests := s.Requests()
+	tests.Assert(t, len(requests) == 3, requests)
+	tests.Assert(t, requests[2].Path == "/volumes/abc")
+}
+
+func TestClientAsyncFailure(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.HandleAsync("POST", "/volumes", &clienttest.AsyncOperation{
+		StatusCode: http.StatusInternalServerError,
+		Body:       "No space",
+	})
+
+	c := NewClientNoAuth(s.URL())
+	_, err := c.VolumeCreate(&api.VolumeCreateRequest{Size: 10})
+	tests.Assert(t, err != nil)
+	tests.Assert(t, err.Error() == "No space", err)
+}
+
+func TestClientHeaders(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.HandleAsync("POST", "/volumes", &clienttest.AsyncOperation{
+		Pending:  1,
+		Location: "/volumes/abc",
+	})
+	s.Handle("GET", "/volumes/abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprint(w, `{"id":"abc","size":10}`)
+	})
+
+	headers := http.Header{}
+	headers.Set("X-Tenant", "tenant1")
+	headers.Set("Authorization", "bearer nottheclienttoken")
+	headers.Set("Content-Type", "text/plain")
+	c := NewClientWithOptions(s.URL(), "admin", TEST_ADMIN_KEY, ClientOptions{
+		Headers: headers,
+	})
+	_, err := c.VolumeCreate(&api.VolumeCreateRequest{Size: 10})
+	tests.Assert(t, err == nil, err)
+
+	// Request, poll and redirect all carry the header
+	requests := s.Requests()
+	tests.Assert(t, len(requests) == 4, requests)
+	for _, r := range requests {
+		tests.Assert(t, r.Header.Get("X-Tenant") == "tenant1", r)
+		tests.Assert(t, r.Header.Get("Authorization") != "bearer nottheclienttoken", r)
+	}
+
+	// Headers set by the client itself take precedence
+	tests.Assert(t, requests[0].Header.Get("Content-Type") == "application/json")
+}
+
+func TestClientOperationLog(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/operations/abc/log", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprint(w, `{"id":"abc","events":[
+			{"time":1,"state":"new","message":"Created"},
+			{"time":2,"state":"failed","message":"Brick create","error":"No space"}]}`)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	events, err := c.OperationLog("abc")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(events) == 2, events)
+	tests.Assert(t, events[1].State == "failed")
+	tests.Assert(t, events[1].Error == "No space")
+
+	requests := s.Requests()
+	tests.Assert(t, requests[0].Header.Get("X-Request-ID") == "abc")
+
+	// Server without operation logs
+	_, err = c.OperationLog("def")
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	// Server which knows operations, but not this one
+	s.Handle("GET", "/operations", func(w http.ResponseWriter, r *http.Request) {
+		tests.Assert(t, r.URL.Query().Get("limit") == "1", r.URL)
+		fmt.Fprint(w, `{"operations":[{"id":"abc"}]}`)
+	})
+	s.Handle("GET", "/operations/def/log", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Id not found", http.StatusNotFound)
+	})
+	_, err = c.OperationLog("def")
+	nerr, ok := err.(*NotFoundError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, nerr.Kind == "operation" && nerr.Id == "def", nerr)
+}
+
+func TestClientDeviceStateIfMatch(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	// Stub which enforces If-Match on state changes
+	etag := `"1"`
+	s.Handle("GET", "/devices/d1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, `{"id":"d1","name":"/dev/sdb","state":"online"}`)
+	})
+	s.Handle("POST", "/devices/d1/state", func(w http.ResponseWriter, r *http.Request) {
+		if match := r.Header.Get("If-Match"); match != "" && match != etag {
+			http.Error(w, "Device d1 was modified", http.StatusPreconditionFailed)
+			return
+		}
+		etag = `"2"`
+		s.StartAsync(w, r, &clienttest.AsyncOperation{})
+	})
+
+	c := NewClientNoAuth(s.URL())
+	device, tag, err := c.DeviceInfoWithETag("d1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, device.Id == "d1")
+	tests.Assert(t, tag == `"1"`, tag)
+
+	offline := &api.StateRequest{State: api.EntryStateOffline}
+	err = c.DeviceStateIfMatch("d1", offline, tag)
+	tests.Assert(t, err == nil, err)
+
+	// The first change updated the ETag, so the old one is stale
+	err = c.DeviceStateIfMatch("d1", offline, tag)
+	tests.Assert(t, err != nil)
+	perr, ok := err.(*PreconditionFailedError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, perr.ETag == `"1"`)
+	tests.Assert(t, perr.Message == "Device d1 was modified", perr.Message)
+
+	// Unconditional requests are not checked
+	err = c.DeviceState("d1", offline)
+	tests.Assert(t, err == nil, err)
+	requests := s.Requests()
+	last := requests[len(requests)-2]
+	tests.Assert(t, last.Method == "POST")
+	tests.Assert(t, last.Header.Get("If-Match") == "")
+}
+
+// Create a stub server with count volumes, each of which takes latency
+// to look up
+func setupVolumeListServer(count int, latency time.Duration) *clienttest.AsyncServer {
+	s := clienttest.NewAsyncServer()
+
+	ids := make([]string, count)
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("%032x", i)
+		ids[i] = `"` + id + `"`
+		s.Handle("GET", "/volumes/"+id, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(latency)
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			fmt.Fprintf(w, `{"id":"%v","size":1}`, id)
+		})
+	}
+	s.Handle("GET", "/volumes", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprintf(w, `{"volumes":[%v]}`, strings.Join(ids, ","))
+	})
+
+	return s
+}
+
+func TestClientVolumeListDetailed(t *testing.T) {
+	s := setupVolumeListServer(20, 0)
+	defer s.Close()
+
+	// A volume which disappears between the list and the lookup
+	missing := fmt.Sprintf("%032x", 5)
+	s.Handle("GET", "/volumes/"+missing, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Id not found", http.StatusNotFound)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	volumes, err := c.VolumeListDetailed(4)
+	tests.Assert(t, err != nil)
+	berr, ok := err.(BulkError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, len(berr) == 1, berr)
+	tests.Assert(t, berr[missing].Error() == "Id not found", berr)
+
+	// All other volumes are returned in list order
+	tests.Assert(t, len(volumes) == 19, len(volumes))
+	tests.Assert(t, volumes[0].Id == fmt.Sprintf("%032x", 0))
+	tests.Assert(t, volumes[5].Id == fmt.Sprintf("%032x", 6))
+}
+
+func BenchmarkVolumeListSerial(b *testing.B) {
+	s := setupVolumeListServer(200, time.Millisecond)
+	defer s.Close()
+
+	c := NewClientNoAuth(s.URL())
+	for n := 0; n < b.N; n++ {
+		list, err := c.VolumeList()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, id := range list.Volumes {
+			if _, err := c.VolumeInfo(id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkVolumeListDetailed(b *testing.B) {
+	s := setupVolumeListServer(200, time.Millisecond)
+	defer s.Close()
+
+	c := NewClientNoAuth(s.URL())
+	for n := 0; n < b.N; n++ {
+		if _, err := c.VolumeListDetailed(16); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type testTokenProvider struct {
+	lock  sync.Mutex
+	calls []string
+}
+
+func (p *testTokenProvider) Token(method, path string) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.calls = append(p.calls, method+" "+path)
+	return fmt.Sprintf("Bearer token%v", len(p.calls)), nil
+}
+
+func TestClientTokenProvider(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.HandleAsync("DELETE", "/volumes/abc", &clienttest.AsyncOperation{
+		Pending: 1,
+	})
+
+	p := &testTokenProvider{}
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		TokenProvider: p,
+	})
+	err := c.VolumeDelete("abc")
+	tests.Assert(t, err == nil, err)
+
+	// Every request asked the provider for a new token
+	requests := s.Requests()
+	tests.Assert(t, len(requests) == 3, requests)
+	tests.Assert(t, len(p.calls) == 3, p.calls)
+	tests.Assert(t, p.calls[0] == "DELETE /volumes/abc", p.calls)
+	for i, r := range requests {
+		tests.Assert(t, p.calls[i] == r.Method+" "+r.Path, p.calls)
+		tests.Assert(t, r.Header.Get("Authorization") == fmt.Sprintf("Bearer token%v", i+1))
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	failing := true
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "Down", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Minute,
+	})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+
+	// Failures below the threshold keep the circuit closed
+	for i := 0; i < 3; i++ {
+		tests.Assert(t, c.BreakerState() == BreakerClosed)
+		err := c.Hello()
+		tests.Assert(t, err != nil && err != ErrCircuitOpen, err)
+	}
+	tests.Assert(t, c.BreakerState() == BreakerOpen)
+
+	// Open circuit fails fast without contacting the server
+	err := c.Hello()
+	tests.Assert(t, err == ErrCircuitOpen, err)
+	tests.Assert(t, len(s.Requests()) == 3)
+
+	// After the cooldown a failed trial opens the circuit again
+	now = now.Add(time.Minute)
+	err = c.Hello()
+	tests.Assert(t, err != nil && err != ErrCircuitOpen, err)
+	tests.Assert(t, len(s.Requests()) == 4)
+	tests.Assert(t, c.BreakerState() == BreakerOpen)
+	err = c.Hello()
+	tests.Assert(t, err == ErrCircuitOpen, err)
+
+	// A successful trial closes it
+	failing = false
+	now = now.Add(time.Minute)
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, c.BreakerState() == BreakerClosed)
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+
+	// Clients without a breaker never open
+	c = NewClientNoAuth(s.URL())
+	tests.Assert(t, c.BreakerState() == BreakerClosed)
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	b := newCircuitBreaker(1, time.Minute)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	tests.Assert(t, b.allow() == nil)
+	b.record(false)
+	tests.Assert(t, b.allow() == ErrCircuitOpen)
+
+	// Only one request probes the server once the cooldown passed
+	now = now.Add(time.Minute)
+	tests.Assert(t, b.allow() == nil)
+	tests.Assert(t, b.getState() == BreakerHalfOpen)
+	tests.Assert(t, b.allow() == ErrCircuitOpen)
+	b.record(true)
+	tests.Assert(t, b.getState() == BreakerClosed)
+	tests.Assert(t, b.allow() == nil)
+}
+
+func TestClientTopologyInfoError(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	json := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			fmt.Fprint(w, body)
+		}
+	}
+	s.Handle("GET", "/clusters", json(`{"clusters":["c1"]}`))
+	s.Handle("GET", "/clusters/c1", json(`{"id":"c1","nodes":["n1","n2"],"volumes":["v1"]}`))
+	s.Handle("GET", "/volumes/v1", json(`{"id":"v1","cluster":"c1"}`))
+	s.Handle("GET", "/nodes/n1", json(`{"id":"n1","devices":[{"id":"d1"}]}`))
+
+	// Node n2 cannot be read so the topology is incomplete
+	c := NewClientNoAuth(s.URL())
+	_, err := c.TopologyInfo()
+	tests.Assert(t, err != nil)
+
+	s.Handle("GET", "/nodes/n2", json(`{"id":"n2"}`))
+	topo, err := c.TopologyInfo()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(topo.ClusterList) == 1)
+	cluster := topo.ClusterList[0]
+	tests.Assert(t, len(cluster.Volumes) == 1)
+	tests.Assert(t, len(cluster.Nodes) == 2)
+	tests.Assert(t, cluster.Nodes[0].Id == "n1")
+	tests.Assert(t, cluster.Nodes[0].DevicesInfo[0].Id == "d1")
+	tests.Assert(t, cluster.Nodes[1].Id == "n2")
+}
+
+func TestClientMaxRedirects(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	// /hello is reached after three redirects
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/r1", http.StatusSeeOther)
+	})
+	s.Handle("GET", "/r1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/r2", http.StatusSeeOther)
+	})
+	s.Handle("GET", "/r2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/r3", http.StatusSeeOther)
+	})
+	s.Handle("GET", "/r3", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	maxRedirects := func(max int) *int {
+		return &max
+	}
+	p := &testTokenProvider{}
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		TokenProvider: p,
+		MaxRedirects:  maxRedirects(3),
+	})
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+
+	// Every hop was signed for its own path
+	requests := s.Requests()
+	tests.Assert(t, len(requests) == 4, requests)
+	for i, r := range requests {
+		tests.Assert(t, p.calls[i] == "GET "+r.Path, p.calls)
+		tests.Assert(t, r.Header.Get("Authorization") == fmt.Sprintf("Bearer token%v", i+1))
+	}
+
+	// One hop too many
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		TokenProvider: p,
+		MaxRedirects:  maxRedirects(2),
+	})
+	err = c.Hello()
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "Stopped after 2 redirects"), err)
+	tests.Assert(t, len(s.Requests()) == 7)
+
+	// Redirects not allowed
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		TokenProvider: p,
+		MaxRedirects:  maxRedirects(0),
+	})
+	err = c.Hello()
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "redirects are disabled"), err)
+	tests.Assert(t, len(s.Requests()) == 8)
+
+	// Redirects are followed if not set
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		TokenProvider: p,
+	})
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(s.Requests()) == 12)
+}
+
+func TestClientResumeOperation(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.HandleAsync("POST", "/volumes", &clienttest.AsyncOperation{
+		Pending:  1,
+		Location: "/volumes/abc",
+	})
+	s.Handle("GET", "/volumes/abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprint(w, `{"id":"abc","size":10}`)
+	})
+	s.HandleAsync("DELETE", "/volumes/abc", &clienttest.AsyncOperation{
+		Pending: 1,
+	})
+	s.HandleAsync("DELETE", "/volumes/def", &clienttest.AsyncOperation{
+		StatusCode: http.StatusInternalServerError,
+		Body:       "Unable to delete volume",
+	})
+
+	// The location of the operation is reported before waiting
+	var started []string
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		OperationStarted: func(method, path, location string) {
+			started = append(started, method+" "+path+" "+location)
+		},
+	})
+	_, err := c.VolumeCreate(&api.VolumeCreateRequest{Size: 10})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(started) == 1, started)
+	tests.Assert(t, started[0] == "POST /volumes "+s.URL()+"/queue/1", started)
+
+	// Start operations without waiting for them, as if the process
+	// which started them had been restarted
+	start := func(path string) string {
+		req, err := http.NewRequest("DELETE", s.URL()+path, nil)
+		tests.Assert(t, err == nil, err)
+		r, err := http.DefaultTransport.RoundTrip(req)
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, r.StatusCode == http.StatusAccepted)
+		return r.Header.Get("Location")
+	}
+	location := start("/volumes/abc")
+	tests.Assert(t, location == "/queue/2", location)
+
+	// Resume waiting using the location or the id
+	c = NewClientNoAuth(s.URL())
+	tests.Assert(t, c.OperationLocation("2") == s.URL()+location)
+	resource, err := c.WaitForOperation(c.OperationLocation("2"),
+		&WaitOptions{PollInterval: time.Millisecond})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, resource == "", resource)
+
+	// Operations which redirect return the resource
+	s.HandleAsync("POST", "/volumes", &clienttest.AsyncOperation{
+		Location: "/volumes/abc",
+	})
+	req, _ := http.NewRequest("POST", s.URL()+"/volumes", nil)
+	r, err := http.DefaultTransport.RoundTrip(req)
+	tests.Assert(t, err == nil, err)
+	resource, err = c.WaitForOperation(r.Header.Get("Location"), nil)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, resource == s.URL()+"/volumes/abc", resource)
+
+	// Failed operations return the error
+	location = start("/volumes/def")
+	_, err = c.WaitForOperation(location, nil)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, err.Error() == "Unable to delete volume", err)
+
+	// Completed operations are forgotten
+	_, err = c.WaitForOperation(location, nil)
+	tests.Assert(t, err != nil)
+}
+
+func TestClientWaitForOperations(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.HandleAsync("DELETE", "/volumes/a", &clienttest.AsyncOperation{
+		Pending: 3,
+	})
+	s.HandleAsync("POST", "/volumes", &clienttest.AsyncOperation{
+		Pending:  1,
+		Location: "/volumes/b",
+	})
+	s.Handle("GET", "/volumes/b", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"b"}`)
+	})
+	s.HandleAsync("DELETE", "/volumes/c", &clienttest.AsyncOperation{
+		StatusCode: http.StatusInternalServerError,
+		Body:       "Unable to delete volume",
+	})
+	s.HandleAsync("DELETE", "/volumes/d", &clienttest.AsyncOperation{
+		Pending: 1000,
+	})
+
+	start := func(method, path string) string {
+		req, err := http.NewRequest(method, s.URL()+path, nil)
+		tests.Assert(t, err == nil, err)
+		r, err := http.DefaultTransport.RoundTrip(req)
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, r.StatusCode == http.StatusAccepted)
+		return r.Header.Get("Location")
+	}
+
+	c := NewClientNoAuth(s.URL())
+
+	// All operations complete
+	locations := []string{
+		start("DELETE", "/volumes/a"),
+		start("POST", "/volumes"),
+	}
+	results, err := c.WaitForOperations(locations,
+		&WaitOptions{PollInterval: 10 * time.Millisecond})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(results) == 2)
+	tests.Assert(t, results[0] == "", results)
+	tests.Assert(t, results[1] == s.URL()+"/volumes/b", results)
+
+	// Failures and timeouts are reported for each operation
+	locations = []string{
+		start("DELETE", "/volumes/c"),
+		start("POST", "/volumes"),
+		start("DELETE", "/volumes/d"),
+	}
+	begin := time.Now()
+	results, err = c.WaitForOperations(locations, &WaitOptions{
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      100 * time.Millisecond,
+	})
+	tests.Assert(t, time.Since(begin) < time.Second)
+	tests.Assert(t, err != nil)
+	bulkErr, ok := err.(BulkError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, len(bulkErr) == 2, bulkErr)
+	tests.Assert(t, bulkErr[locations[0]].Error() == "Unable to delete volume",
+		bulkErr)
+	tests.Assert(t, bulkErr[locations[2]] == ErrWaitTimeout, bulkErr)
+	tests.Assert(t, results[1] == s.URL()+"/volumes/b", results)
+}
+
+func TestClientVolumeRebalance(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.HandleAsync("POST", "/volumes/abc/rebalance", &clienttest.AsyncOperation{
+		Pending: 3,
+		PendingBodies: []string{
+			`{"state":"in progress","percent_complete":10,"files_moved":5}`,
+			`{"state":"in progress","percent_complete":60,"files_moved":30}`,
+		},
+		Location: "/volumes/abc/rebalance",
+	})
+	s.Handle("GET", "/volumes/abc/rebalance", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"state":"completed","percent_complete":100,"files_moved":50}`)
+	})
+	s.Handle("POST", "/volumes/busy/rebalance", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Rebalance in progress", http.StatusConflict)
+	})
+	s.Handle("POST", "/volumes/empty/rebalance", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	c := NewClientNoAuth(s.URL())
+
+	var progress []int
+	status, err := c.VolumeRebalance("abc", &VolumeRebalanceOptions{
+		PollInterval: time.Millisecond,
+		Progress: func(status *api.VolumeRebalanceStatus) {
+			tests.Assert(t, status.State == api.RebalanceInProgress)
+			progress = append(progress, status.PercentComplete)
+		},
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, status.State == api.RebalanceCompleted, status)
+	tests.Assert(t, status.FilesMoved == 50, status)
+	tests.Assert(t, len(progress) == 2, progress)
+	tests.Assert(t, progress[0] == 10 && progress[1] == 60, progress)
+
+	_, err = c.VolumeRebalance("busy", nil)
+	tests.Assert(t, err == ErrRebalanceInProgress, err)
+
+	_, err = c.VolumeRebalance("empty", nil)
+	tests.Assert(t, err == ErrNothingToRebalance, err)
+
+	// Servers without volume rebalance
+	s.Handle("GET", "/volumes/old", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		fmt.Fprint(w, `{"id":"old"}`)
+	})
+	s.Handle("POST", "/volumes/old/rebalance", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+	_, err = c.VolumeRebalance("old", nil)
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	// Missing volumes
+	_, err = c.VolumeRebalance("gone", nil)
+	rerr, ok := err.(*RequestError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, rerr.StatusCode == http.StatusNotFound, rerr)
+}
+
+func TestClientClockSkew(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var offset time.Duration
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date",
+			time.Now().Add(offset).UTC().Format(http.TimeFormat))
+	})
+
+	// Disabled by default
+	offset = time.Hour
+	c := NewClientNoAuth(s.URL())
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		MaxClockSkew: time.Minute,
+	})
+
+	// Within the bound
+	offset = 10 * time.Second
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+
+	// Server ahead and behind the local clock
+	for _, offset = range []time.Duration{time.Hour, -time.Hour} {
+		err = c.Hello()
+		tests.Assert(t, err != nil)
+		skewErr, ok := err.(*ClockSkewError)
+		tests.Assert(t, ok, err)
+		tests.Assert(t, skewErr.Skew > 59*time.Minute, skewErr.Skew)
+		tests.Assert(t, skewErr.Skew < 61*time.Minute, skewErr.Skew)
+		tests.Assert(t, strings.Contains(err.Error(), "Server clock differs"), err)
+	}
+}
+
+func TestClientVolumeExpandAndWait(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	bricks := `{"id":"b1"},{"id":"b2"}`
+	s.Handle("GET", "/volumes/abc", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"abc","size":10,"bricks":[%v]}`, bricks)
+	})
+	s.HandleAsync("POST", "/volumes/abc/expand", &clienttest.AsyncOperation{
+		Location: "/volumes/abc/expanded",
+	})
+	s.Handle("GET", "/volumes/abc/expanded", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"abc","size":20,"bricks":[%v,{"id":"b3"},{"id":"b4"}]}`,
+			bricks)
+	})
+
+	// The new bricks come online after a few polls, b4 never does
+	var polls int
+	s.Handle("GET", "/volumes/abc/bricks", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		fmt.Fprintf(w, `{"bricks":[{"id":"b1","online":true},`+
+			`{"id":"b2","online":true},{"id":"b3","online":%v},`+
+			`{"id":"b4","online":false}]}`, polls > 2)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	volume, err := c.VolumeExpandAndWait("abc", 10, &VolumeExpandWaitOptions{
+		PollInterval: time.Millisecond,
+		Timeout:      50 * time.Millisecond,
+	})
+	tests.Assert(t, volume != nil)
+	tests.Assert(t, volume.Size == 20, volume)
+	offline, ok := err.(*BricksOfflineError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, offline.VolumeId == "abc")
+	tests.Assert(t, len(offline.Bricks) == 1 && offline.Bricks[0] == "b4",
+		offline.Bricks)
+
+	// All new bricks online
+	s.Handle("GET", "/volumes/abc/bricks", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"bricks":[{"id":"b1","online":false},`+
+			`{"id":"b3","online":true},{"id":"b4","online":true}]}`)
+	})
+	volume, err = c.VolumeExpandAndWait("abc", 10, &VolumeExpandWaitOptions{
+		PollInterval: time.Millisecond,
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, volume.Size == 20, volume)
+
+	// Servers without brick status do not expand the volume
+	s.Handle("GET", "/volumes/abc/bricks", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	sent := len(s.Requests())
+	volume, err = c.VolumeExpandAndWait("abc", 10, nil)
+	tests.Assert(t, err == ErrNotSupported, err)
+	tests.Assert(t, volume == nil, volume)
+	for _, r := range s.Requests()[sent:] {
+		tests.Assert(t, r.Method == "GET", r.Method, r.Path)
+	}
+
+	// Missing volumes
+	_, err = c.VolumeExpandAndWait("def", 10, nil)
+	rerr, ok := err.(*RequestError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, rerr.StatusCode == http.StatusNotFound, rerr)
+}
+
+func TestClientOperationsList(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	c := NewClientNoAuth(s.URL())
+
+	// Servers without the endpoint
+	_, err := c.OperationsList(nil)
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	pages := map[string]string{
+		"": `{"operations":[{"id":"1","type":"volume_create","state":"pending","start_time":100},` +
+			`{"id":"2","type":"volume_delete","state":"pending","start_time":101}],"next":"2"}`,
+		"2": `{"operations":[{"id":"3","type":"volume_expand","state":"pending","start_time":102}]}`,
+	}
+	s.Handle("GET", "/operations", func(w http.ResponseWriter, r *http.Request) {
+		tests.Assert(t, r.URL.Query().Get("state") == api.OperationPending, r.URL)
+		tests.Assert(t, r.URL.Query().Get("limit") == "2", r.URL)
+		fmt.Fprint(w, pages[r.URL.Query().Get("marker")])
+	})
+
+	operations, err := c.OperationsList(&OperationsFilter{
+		State:    api.OperationPending,
+		PageSize: 2,
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(operations) == 3, operations)
+	for i, op := range operations {
+		tests.Assert(t, op.Id == fmt.Sprintf("%v", i+1), operations)
+		tests.Assert(t, op.State == api.OperationPending, operations)
+	}
+	tests.Assert(t, operations[2].Type == "volume_expand", operations)
+	tests.Assert(t, operations[2].StartTime == 102, operations)
+}
+
+func TestClientClose(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {})
+
+	c := NewClientNoAuth(s.URL())
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+
+	// Close is safe to call concurrently and more than once
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tests.Assert(t, c.Close() == nil)
+		}()
+	}
+	wg.Wait()
+	tests.Assert(t, c.Close() == nil)
+
+	err = c.Hello()
+	tests.Assert(t, err == ErrClientClosed, err)
+	_, err = c.VolumeList()
+	tests.Assert(t, err == ErrClientClosed, err)
+}
+
+func TestClientCloseCancelsRequests(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	release := make(chan bool)
+	defer close(release)
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	s.HandleAsync("DELETE", "/volumes/v1", &clienttest.AsyncOperation{
+		Pending: 1000,
+	})
+
+	c := NewClientNoAuth(s.URL())
+	errs := make(chan error, 2)
+	go func() {
+		errs <- c.Hello()
+	}()
+	go func() {
+		errs <- c.VolumeDelete("v1")
+	}()
+
+	// Wait for the first status poll before closing
+	for polled := false; !polled; {
+		time.Sleep(10 * time.Millisecond)
+		for _, r := range s.Requests() {
+			polled = polled || strings.HasPrefix(r.Path, ASYNC_ROUTE+"/")
+		}
+	}
+	tests.Assert(t, c.Close() == nil)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			tests.Assert(t, err == ErrClientClosed, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Request not cancelled by Close")
+		}
+	}
+}
+
+func TestClientStallTimeout(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	start := func(path string) string {
+		req, err := http.NewRequest("DELETE", s.URL()+path, nil)
+		tests.Assert(t, err == nil, err)
+		r, err := http.DefaultTransport.RoundTrip(req)
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, r.StatusCode == http.StatusAccepted)
+		return r.Header.Get("Location")
+	}
+
+	// Progressing slowly, but always within the stall timeout
+	progress := make([]string, 30)
+	for i := range progress {
+		progress[i] = fmt.Sprintf("%v%%", i)
+	}
+	s.HandleAsync("DELETE", "/volumes/slow", &clienttest.AsyncOperation{
+		Pending:         len(progress),
+		PendingProgress: progress,
+	})
+
+	// Progress stops after a few polls
+	s.HandleAsync("DELETE", "/volumes/stuck", &clienttest.AsyncOperation{
+		Pending:         1000,
+		PendingProgress: []string{"1%", "2%", "3%"},
+	})
+
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		StallTimeout: 50 * time.Millisecond,
+	})
+	opts := &WaitOptions{PollInterval: 5 * time.Millisecond}
+
+	_, err := c.WaitForOperation(start("/volumes/slow"), opts)
+	tests.Assert(t, err == nil, err)
+
+	begin := time.Now()
+	_, err = c.WaitForOperation(start("/volumes/stuck"), opts)
+	tests.Assert(t, err == ErrOperationStalled, err)
+	tests.Assert(t, time.Since(begin) < time.Second)
+
+	// Disabled by default
+	c = NewClientNoAuth(s.URL())
+	s.HandleAsync("DELETE", "/volumes/quiet", &clienttest.AsyncOperation{
+		Pending: 20,
+	})
+	_, err = c.WaitForOperation(start("/volumes/quiet"), opts)
+	tests.Assert(t, err == nil, err)
+}
+
+func TestClientVolumeDeleteAndVerify(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/volumes/abc", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"abc","bricks":[`+
+			`{"id":"b1","device":"d1","node":"n1","path":"/p/b1","volume":"abc"},`+
+			`{"id":"b2","device":"d2","node":"n2","path":"/p/b2","volume":"abc"},`+
+			`{"id":"b3","device":"d1","node":"n1","path":"/p/b3","volume":"abc"}]}`)
+	})
+	s.HandleAsync("DELETE", "/volumes/abc", &clienttest.AsyncOperation{})
+	s.Handle("GET", "/devices/d1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"d1"}`)
+	})
+
+	// The server cannot scan devices, so the volume is not deleted
+	c := NewClientNoAuth(s.URL())
+	err := c.VolumeDeleteAndVerify("abc", nil)
+	tests.Assert(t, err == ErrNotSupported, err)
+	for _, r := range s.Requests() {
+		tests.Assert(t, r.Method != "DELETE", r)
+	}
+
+	// Every brick was removed
+	var lock sync.Mutex
+	devices := map[string]string{
+		"d1": `{"bricks":["x1"]}`,
+		"d2": `{"bricks":[]}`,
+	}
+	for id := range devices {
+		id := id
+		s.Handle("GET", "/devices/"+id+"/scan", func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			fmt.Fprint(w, devices[id])
+		})
+	}
+	err = c.VolumeDeleteAndVerify("abc", nil)
+	tests.Assert(t, err == nil, err)
+
+	// Bricks were left behind on a node which was down
+	lock.Lock()
+	devices["d1"] = `{"bricks":["b3","b1","x1"]}`
+	lock.Unlock()
+	err = c.VolumeDeleteAndVerify("abc", nil)
+	orphans, ok := err.(*OrphanedBricksError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, orphans.VolumeId == "abc")
+	tests.Assert(t, len(orphans.Bricks) == 2, orphans.Bricks)
+	tests.Assert(t, orphans.Bricks[0].Id == "b1" && orphans.Bricks[1].Id == "b3",
+		orphans.Bricks)
+	tests.Assert(t, strings.Contains(err.Error(), "b1 on node n1 (/p/b1)"), err)
+}
+
+func TestClientTokenClaims(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var claims jwt.MapClaims
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {
+		raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		claims = jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+			return []byte("secret"), nil
+		})
+		tests.Assert(t, err == nil, err)
+	})
+
+	c := NewClientWithOptions(s.URL(), "admin", "secret", ClientOptions{
+		Audience: "heketi.example.com",
+		Claims: map[string]interface{}{
+			"tenant": "storage-team",
+			"roles":  []string{"provisioner"},
+		},
+	})
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, claims["aud"] == "heketi.example.com", claims)
+	tests.Assert(t, claims["tenant"] == "storage-team", claims)
+	tests.Assert(t, claims["iss"] == "admin", claims)
+	tests.Assert(t, claims["qsh"] != nil, claims)
+	roles, ok := claims["roles"].([]interface{})
+	tests.Assert(t, ok && len(roles) == 1 && roles[0] == "provisioner", claims)
+
+	// Reserved claims cannot be given
+	for _, reserved := range []string{"qsh", "exp", "aud"} {
+		_, err = NewJwtTokenProviderWithClaims("admin", "secret", "",
+			map[string]interface{}{reserved: "x"})
+		tests.Assert(t, err != nil)
+		tests.Assert(t, strings.Contains(err.Error(), reserved), err)
+
+		c = NewClientWithOptions(s.URL(), "admin", "secret", ClientOptions{
+			Claims: map[string]interface{}{reserved: "x"},
+		})
+		err = c.Hello()
+		tests.Assert(t, err != nil)
+		tests.Assert(t, strings.Contains(err.Error(), "is reserved"), err)
+	}
+}
+
+func TestClientAPIVersion(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {})
+
+	// No Accept header by default
+	c := NewClientNoAuth(s.URL())
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, c.CheckAPIVersion() == nil)
+	requests := s.Requests()
+	tests.Assert(t, requests[len(requests)-1].Header.Get("Accept") == "")
+
+	// Servers without /info only support version 1
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{APIVersion: 1})
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+	requests = s.Requests()
+	tests.Assert(t, requests[len(requests)-1].Header.Get("Accept") ==
+		"application/vnd.heketi.v1+json", requests[len(requests)-1].Header)
+	tests.Assert(t, c.CheckAPIVersion() == nil)
+
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{APIVersion: 2})
+	err = c.CheckAPIVersion()
+	verr, ok := err.(*APIVersionError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, verr.Requested == 2)
+
+	// Versions advertised by the server
+	s.Handle("GET", "/info", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version":"7.0.0","api_versions":[1,2]}`)
+	})
+	info, err := c.ServerInfo()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Version == "7.0.0", info)
+	tests.Assert(t, c.CheckAPIVersion() == nil)
+
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{APIVersion: 3})
+	err = c.CheckAPIVersion()
+	verr, ok = err.(*APIVersionError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, reflect.DeepEqual(verr.Supported, []int{1, 2}), verr)
+}
+
+func TestClientRequestID(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/clusters", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "id-list")
+		fmt.Fprint(w, `{"clusters":[]}`)
+	})
+	s.Handle("GET", "/clusters/abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "id-info")
+		http.Error(w, "Id not found", http.StatusNotFound)
+	})
+	s.Handle("GET", "/clusters/def", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Id not found", http.StatusNotFound)
+	})
+
+	var completed []string
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		RequestCompleted: func(method, path, requestID string, statusCode int) {
+			completed = append(completed,
+				fmt.Sprintf("%v %v %v %v", method, path, requestID, statusCode))
+		},
+	})
+
+	// Successful requests report the id through the callback
+	_, err := c.ClusterList()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(completed) == 1, completed)
+	tests.Assert(t, completed[0] == "GET /clusters id-list 200", completed)
+
+	// Failed requests also carry it on the error
+	_, err = c.ClusterInfo("abc")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, err.Error() == "Id not found", err)
+	tests.Assert(t, RequestIDFromError(err) == "id-info", err)
+	rerr, ok := err.(*RequestError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, rerr.StatusCode == http.StatusNotFound)
+	tests.Assert(t, completed[1] == "GET /clusters/abc id-info 404", completed)
+
+	// Servers which do not assign ids
+	_, err = c.ClusterInfo("def")
+	tests.Assert(t, err.Error() == "Id not found", err)
+	tests.Assert(t, RequestIDFromError(err) == "", err)
+	rerr, ok = err.(*RequestError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, rerr.StatusCode == http.StatusNotFound)
+}
+
+func TestClientDevicesAdd(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/nodes/n1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n1","devices":[{"name":"/dev/sdb","id":"d1"}]}`)
+	})
+	var lock sync.Mutex
+	attempts := make(map[string]int)
+	s.Handle("GET", "/nodes/n2", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if attempts["n2/dev/sdf"] != 0 {
+			fmt.Fprint(w, `{"id":"n2","devices":[{"name":"/dev/sdf","id":"d2"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"n2","devices":[]}`)
+	})
+
+	// The server is unavailable for the first attempts of /dev/sdd,
+	// /dev/sde can never be added and /dev/sdf is added although the
+	// gateway times out
+	s.Handle("POST", "/devices", func(w http.ResponseWriter, r *http.Request) {
+		var req api.DeviceAddRequest
+		err := utils.GetJsonFromRequest(r, &req)
+		tests.Assert(t, err == nil, err)
+		lock.Lock()
+		attempts[req.NodeId+req.Name]++
+		n := attempts[req.NodeId+req.Name]
+		lock.Unlock()
+
+		switch {
+		case req.Name == "/dev/sdd" && n < 3:
+			http.Error(w, "Server busy", http.StatusServiceUnavailable)
+		case req.Name == "/dev/sde":
+			http.Error(w, "Unable to add device", http.StatusBadRequest)
+		case req.Name == "/dev/sdf":
+			http.Error(w, "Gateway timeout", http.StatusGatewayTimeout)
+		default:
+			s.StartAsync(w, r, &clienttest.AsyncOperation{})
+		}
+	})
+
+	requests := []*api.DeviceAddRequest{}
+	for _, add := range []struct{ node, name string }{
+		{"n1", "/dev/sdb"},
+		{"n1", "/dev/sdc"},
+		{"n2", "/dev/sdb"},
+		{"n2", "/dev/sdd"},
+		{"n2", "/dev/sde"},
+		{"n2", "/dev/sdf"},
+	} {
+		request := &api.DeviceAddRequest{NodeId: add.node}
+		request.Name = add.name
+		requests = append(requests, request)
+	}
+
+	c := NewClientNoAuth(s.URL())
+	var progress []int
+	results, err := c.DevicesAdd(requests, &DevicesAddOptions{
+		Concurrency: 1,
+		RetryDelay:  time.Millisecond,
+		Progress: func(result *DeviceAddResult, done, total int) {
+			tests.Assert(t, total == 6)
+			progress = append(progress, done)
+		},
+	})
+	tests.Assert(t, reflect.DeepEqual(progress, []int{1, 2, 3, 4, 5, 6}), progress)
+	tests.Assert(t, len(results) == 6, results)
+
+	// Already present on its node
+	tests.Assert(t, results[0].Skipped && results[0].Attempts == 0, results[0])
+	tests.Assert(t, attempts["n1/dev/sdb"] == 0, attempts)
+
+	// Added on the first attempt
+	tests.Assert(t, !results[1].Skipped && results[1].Err == nil, results[1])
+	tests.Assert(t, results[1].Attempts == 1, results[1])
+	tests.Assert(t, results[2].Err == nil && results[2].Attempts == 1, results[2])
+
+	// Retried while the server was unavailable
+	tests.Assert(t, results[3].Err == nil && results[3].Attempts == 3, results[3])
+
+	// Permanent failures are not retried
+	tests.Assert(t, results[4].Err != nil && results[4].Attempts == 1, results[4])
+
+	// Found on the node before the retry
+	tests.Assert(t, results[5].Err == nil && results[5].Attempts == 1, results[5])
+	tests.Assert(t, attempts["n2/dev/sdf"] == 1, attempts)
+
+	bulkErr, ok := err.(BulkError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, len(bulkErr) == 1, bulkErr)
+	tests.Assert(t, bulkErr["n2:/dev/sde"].Error() == "Unable to add device", bulkErr)
+}
+
+func TestIsTransientError(t *testing.T) {
+	// Nothing listens on the port of a closed server
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+	_, err := http.Get(s.URL)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, isTransientError(err), err)
+
+	_, err = http.Get("foo://localhost/")
+	tests.Assert(t, err != nil)
+	tests.Assert(t, !isTransientError(err), err)
+
+	reset := &url.Error{Op: "Post", URL: "http://localhost/", Err: &net.OpError{
+		Op:  "read",
+		Err: os.NewSyscallError("read", syscall.ECONNRESET),
+	}}
+	tests.Assert(t, isTransientError(reset))
+
+	tests.Assert(t, isTransientError(&RequestError{StatusCode: http.StatusServiceUnavailable}))
+	tests.Assert(t, !isTransientError(&RequestError{StatusCode: http.StatusBadRequest}))
+	tests.Assert(t, !isTransientError(errors.New("failed")))
+}
+
+func TestClientBodyReadTimeout(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	// Send the headers and part of the body, then stall
+	release := make(chan bool)
+	defer close(release)
+	s.Handle("GET", "/clusters", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"clusters":[`)
+		w.(http.Flusher).Flush()
+		<-release
+	})
+	s.Handle("GET", "/clusters/abc", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"abc","nodes":[],"volumes":[]}`)
+	})
+
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		BodyReadTimeout: 50 * time.Millisecond,
+	})
+
+	begin := time.Now()
+	_, err := c.ClusterList()
+	tests.Assert(t, err == ErrBodyReadTimeout, err)
+	tests.Assert(t, time.Since(begin) < time.Second)
+
+	// Bodies read in time are not affected
+	info, err := c.ClusterInfo("abc")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Id == "abc")
+}
+
+type testSpan struct {
+	tracer     *testTracer
+	name       string
+	parent     *testSpan
+	attributes map[string]string
+	events     []string
+	ended      bool
+	err        error
+}
+
+func (s *testSpan) Inject(header http.Header) {
+	header.Set("Traceparent", "trace-"+s.name)
+}
+
+func (s *testSpan) SetAttribute(key, value string) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *testSpan) AddEvent(name string, attributes map[string]string) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.events = append(s.events, name+" "+attributes["heketi.progress"])
+}
+
+func (s *testSpan) End(err error) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.ended = true
+	s.err = err
+}
+
+type testTracer struct {
+	lock  sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(name string, parent Span) Span {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	s := &testSpan{
+		tracer:     t,
+		name:       name,
+		attributes: map[string]string{},
+	}
+	if parent != nil {
+		s.parent = parent.(*testSpan)
+	}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+func TestClientTracer(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("DELETE", "/volumes/abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-1")
+		s.StartAsync(w, r, &clienttest.AsyncOperation{
+			Pending:         2,
+			PendingProgress: []string{"10%", "50%"},
+		})
+	})
+
+	tracer := &testTracer{}
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		Tracer: tracer,
+	})
+	err := c.VolumeDelete("abc")
+	tests.Assert(t, err == nil, err)
+
+	// The request, the wait and its three polls
+	spans := tracer.spans
+	tests.Assert(t, len(spans) == 5, len(spans))
+	tests.Assert(t, spans[0].name == "heketi DELETE /volumes/abc", spans[0].name)
+	tests.Assert(t, spans[0].parent == nil)
+	tests.Assert(t, spans[0].attributes["http.status_code"] == "202", spans[0].attributes)
+	tests.Assert(t, spans[0].attributes["heketi.request_id"] == "req-1", spans[0].attributes)
+
+	wait := spans[1]
+	tests.Assert(t, wait.name == "heketi wait /queue/1", wait.name)
+	tests.Assert(t, wait.attributes["heketi.operation_location"] == s.URL()+"/queue/1",
+		wait.attributes)
+	tests.Assert(t, reflect.DeepEqual(wait.events, []string{"pending 10%", "pending 50%"}),
+		wait.events)
+	for _, poll := range spans[2:] {
+		tests.Assert(t, poll.name == "heketi GET /queue/1", poll.name)
+		tests.Assert(t, poll.parent == wait)
+	}
+	for _, span := range spans {
+		tests.Assert(t, span.ended && span.err == nil, span.name)
+	}
+
+	// The trace context is sent to the server
+	requests := s.Requests()
+	tests.Assert(t, requests[0].Header.Get("Traceparent") ==
+		"trace-heketi DELETE /volumes/abc", requests[0].Header)
+}
+
+func TestClientCapacityInfo(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var lock sync.Mutex
+	requests := 0
+	count := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests++
+			lock.Unlock()
+			h(w, r)
+		}
+	}
+	s.Handle("GET", "/clusters", count(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"clusters":["c1","c2"]}`)
+	}))
+	s.Handle("GET", "/clusters/c1", count(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","nodes":["n1","n2"],"volumes":[]}`)
+	}))
+	s.Handle("GET", "/clusters/c2", count(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"c2","nodes":["n3"],"volumes":[]}`)
+	}))
+	s.Handle("GET", "/nodes/n1", count(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n1","state":"online","devices":[`+
+			`{"id":"d1","state":"online","storage":{"total":100,"used":40,"free":60}},`+
+			`{"id":"d2","state":"offline","storage":{"total":100,"used":0,"free":100}}]}`)
+	}))
+	s.Handle("GET", "/nodes/n2", count(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n2","state":"online","devices":[`+
+			`{"id":"d3","state":"online","storage":{"total":200,"used":50,"free":150}}]}`)
+	}))
+	s.Handle("GET", "/nodes/n3", count(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n3","state":"offline","devices":[`+
+			`{"id":"d4","state":"online","storage":{"total":100,"used":0,"free":100}}]}`)
+	}))
+	for id, device := range map[string]string{
+		"d1": `{"id":"d1","state":"online","storage":{"total":100,"used":40,"free":60}}`,
+		"d2": `{"id":"d2","state":"offline","storage":{"total":100,"used":0,"free":100}}`,
+		"d3": `{"id":"d3","state":"online","storage":{"total":200,"used":50,"free":150}}`,
+		"d4": `{"id":"d4","state":"online","storage":{"total":100,"used":0,"free":100}}`,
+	} {
+		device := device
+		s.Handle("GET", "/devices/"+id, count(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, device)
+		}))
+	}
+
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		CacheTTL: time.Hour,
+	})
+	report, err := c.CapacityInfo()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(report.Clusters) == 2, report)
+
+	c1 := report.Clusters[0]
+	tests.Assert(t, c1.ClusterId == "c1")
+	tests.Assert(t, c1.Total == 400*1024, c1)
+	tests.Assert(t, c1.Used == 90*1024, c1)
+	tests.Assert(t, c1.Free == 210*1024, c1)
+	tests.Assert(t, c1.LargestFree == 150*1024, c1)
+
+	// Nothing is usable on an offline node
+	c2 := report.Clusters[1]
+	tests.Assert(t, c2.ClusterId == "c2")
+	tests.Assert(t, c2.Total == 100*1024 && c2.Free == 0 && c2.LargestFree == 0, c2)
+
+	tests.Assert(t, report.Total == 500*1024, report)
+	tests.Assert(t, report.Free == 210*1024, report)
+
+	tests.Assert(t, requests == 10, requests)
+
+	// Repeated calls are answered from the response cache
+	report, err = c.CapacityInfo()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, requests == 10, requests)
+	tests.Assert(t, report.Clusters[0].Free == 210*1024, report)
+}
+
+func TestClientResponseCache(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var lock sync.Mutex
+	gets := map[string]int{}
+	s.Handle("GET", "/volumes", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		gets[r.URL.Path]++
+		lock.Unlock()
+		fmt.Fprint(w, `{"volumes":["v1"]}`)
+	})
+	s.Handle("GET", "/volumes/v1", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		gets[r.URL.Path]++
+		lock.Unlock()
+		w.Header().Set("ETag", `"1"`)
+		if r.Header.Get("If-None-Match") == `"1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(w, `{"id":"v1","name":"vol_v1"}`)
+	})
+	s.Handle("GET", "/volumes/v2", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		gets[r.URL.Path]++
+		lock.Unlock()
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(w, `{"id":"v2"}`)
+	})
+	s.HandleAsync("DELETE", "/volumes/v1", &clienttest.AsyncOperation{})
+
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		CacheTTL: time.Hour,
+	})
+
+	// Repeated reads are answered from the cache
+	for i := 0; i < 3; i++ {
+		volume, err := c.VolumeInfo("v1")
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, volume.Name == "vol_v1", volume)
+		_, err = c.VolumeList()
+		tests.Assert(t, err == nil, err)
+	}
+	tests.Assert(t, gets["/volumes/v1"] == 1, gets)
+	tests.Assert(t, gets["/volumes"] == 1, gets)
+
+	// Responses the server marks as not storable are always fetched
+	for i := 0; i < 2; i++ {
+		_, err := c.VolumeInfo("v2")
+		tests.Assert(t, err == nil, err)
+	}
+	tests.Assert(t, gets["/volumes/v2"] == 2, gets)
+
+	// Writing a volume drops its reads and the list which includes it
+	err := c.VolumeDelete("v1")
+	tests.Assert(t, err == nil, err)
+	_, err = c.VolumeList()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, gets["/volumes"] == 2, gets)
+	_, err = c.VolumeInfo("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, gets["/volumes/v1"] == 2, gets)
+
+	// Expired responses with an ETag are revalidated
+	c.cache.lock.Lock()
+	for key, entry := range c.cache.entries {
+		expired := *entry
+		expired.expires = time.Now()
+		c.cache.entries[key] = &expired
+	}
+	c.cache.lock.Unlock()
+	volume, err := c.VolumeInfo("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, volume.Name == "vol_v1", volume)
+	tests.Assert(t, gets["/volumes/v1"] == 3, gets)
+	requests := s.Requests()
+	last := requests[len(requests)-1]
+	tests.Assert(t, last.Header.Get("If-None-Match") == `"1"`, last)
+}
+
+func TestClientResponseCacheRelated(t *testing.T) {
+	rc := newResponseCache(time.Hour, 0)
+	gets := map[string]int{}
+	pending := true
+	send := func(req *http.Request) (*http.Response, error) {
+		r := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+			Request:    req,
+		}
+		switch {
+		case req.URL.Path == ASYNC_ROUTE+"/op1":
+			if pending {
+				r.Header.Set("X-Pending", "true")
+			} else {
+				r.StatusCode = http.StatusNoContent
+			}
+		case req.Method != "GET":
+			r.StatusCode = http.StatusAccepted
+			r.Header.Set("Location", ASYNC_ROUTE+"/op1")
+		default:
+			gets[req.URL.Path]++
+		}
+		return r, nil
+	}
+	do := func(method, path string) {
+		req, err := http.NewRequest(method, "http://heketi"+path, nil)
+		tests.Assert(t, err == nil, err)
+		r, err := rc.do(req, send)
+		tests.Assert(t, err == nil, err)
+		r.Body.Close()
+	}
+
+	do("GET", "/clusters/c1")
+	do("GET", "/nodes/n1")
+
+	// Creating a volume drops the clusters, which list their volumes
+	do("POST", "/volumes")
+	do("GET", "/clusters/c1")
+	do("GET", "/nodes/n1")
+	tests.Assert(t, gets["/clusters/c1"] == 2, gets)
+	tests.Assert(t, gets["/nodes/n1"] == 1, gets)
+
+	// While the operation runs the reads are cached again, and are
+	// dropped once it finishes
+	do("GET", ASYNC_ROUTE+"/op1")
+	do("GET", "/clusters/c1")
+	tests.Assert(t, gets["/clusters/c1"] == 2, gets)
+	pending = false
+	do("GET", ASYNC_ROUTE+"/op1")
+	do("GET", "/clusters/c1")
+	tests.Assert(t, gets["/clusters/c1"] == 3, gets)
+	tests.Assert(t, len(rc.pending) == 0, rc.pending)
+
+	// Changing the state of a device drops the nodes, which list their
+	// devices
+	do("POST", "/devices/d1/state")
+	do("GET", "/nodes/n1")
+	do("GET", "/clusters/c1")
+	tests.Assert(t, gets["/nodes/n1"] == 2, gets)
+	tests.Assert(t, gets["/clusters/c1"] == 3, gets)
+}
+
+func TestClientResponseCacheConcurrent(t *testing.T) {
+	// Entries expire at once, so every read after the first one
+	// revalidates the entry while the others read it
+	rc := newResponseCache(time.Nanosecond, 0)
+	send := func(req *http.Request) (*http.Response, error) {
+		// Let the other readers run while this one waits
+		runtime.Gosched()
+		r := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Etag": {`"1"`}},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":"v1"}`)),
+			Request:    req,
+		}
+		if req.Header.Get("If-None-Match") == `"1"` {
+			r.StatusCode = http.StatusNotModified
+			r.Body = ioutil.NopCloser(strings.NewReader(""))
+		}
+		return r, nil
+	}
+	get := func() (string, error) {
+		req, err := http.NewRequest("GET", "http://heketi/volumes/v1", nil)
+		if err != nil {
+			return "", err
+		}
+		r, err := rc.do(req, send)
+		if err != nil {
+			return "", err
+		}
+		defer r.Body.Close()
+		body, err := ioutil.ReadAll(r.Body)
+		return string(body), err
+	}
+
+	bodies := make(chan string, 8*20)
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func() {
+			for j := 0; j < 20; j++ {
+				body, err := get()
+				if err != nil {
+					errs <- err
+					return
+				}
+				bodies <- body
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		err := <-errs
+		tests.Assert(t, err == nil, err)
+	}
+	close(bodies)
+	for body := range bodies {
+		tests.Assert(t, body == `{"id":"v1"}`, body)
+	}
+}
+
+func TestClientResponseCacheSize(t *testing.T) {
+	rc := newResponseCache(time.Hour, 2)
+	for i, key := range []string{"http://h/a", "http://h/b", "http://h/c"} {
+		rc.store(key, &cachedResponse{
+			expires: time.Now().Add(time.Duration(i+1) * time.Minute),
+		})
+	}
+	tests.Assert(t, len(rc.entries) == 2, rc.entries)
+	_, ok := rc.entries["http://h/a"]
+	tests.Assert(t, !ok, rc.entries)
+}
+
+func TestClientVolumeSetNameAndTags(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var lock sync.Mutex
+	volume := api.VolumeInfoResponse{}
+	volume.Id = "v1"
+	volume.Name = "vol_v1"
+	s.Handle("GET", "/volumes/v1", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&volume)
+	})
+	update := func(w http.ResponseWriter, r *http.Request) {
+		var request api.VolumeUpdateRequest
+		err := utils.GetJsonFromRequest(r, &request)
+		tests.Assert(t, err == nil, err)
+		switch {
+		case request.Name == "taken":
+			http.Error(w, "Name taken", http.StatusConflict)
+		case request.Name == "reserved":
+			http.Error(w, "Name reserved", http.StatusBadRequest)
+		case request.Name != "":
+			lock.Lock()
+			volume.Name = request.Name
+			lock.Unlock()
+			s.StartAsync(w, r, &clienttest.AsyncOperation{
+				Pending:  1,
+				Location: "/volumes/v1",
+			})
+		default:
+			lock.Lock()
+			volume.Tags = request.Tags
+			lock.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	s.Handle("POST", "/volumes/v1/update", update)
+
+	c := NewClientNoAuth(s.URL())
+
+	// Renames are waited for
+	v, err := c.VolumeSetName("v1", "billing")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, v.Name == "billing", v)
+
+	v, err = c.VolumeSetTags("v1", map[string]string{"owner": "team-a"})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, v.Tags["owner"] == "team-a", v)
+
+	_, err = c.VolumeSetName("v1", "taken")
+	tests.Assert(t, err == ErrVolumeNameTaken, err)
+
+	_, err = c.VolumeSetName("v1", "reserved")
+	_, ok := err.(*InvalidVolumeUpdateError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, strings.Contains(err.Error(), "Name reserved"), err)
+
+	// Invalid names are rejected without asking the server
+	sent := len(s.Requests())
+	_, err = c.VolumeSetName("v1", "bad name")
+	_, ok = err.(*InvalidVolumeUpdateError)
+	tests.Assert(t, ok, err)
+	_, err = c.VolumeSetName("v1", "")
+	_, ok = err.(*InvalidVolumeUpdateError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, len(s.Requests()) == sent)
+
+	// Servers without volume updates
+	s.Handle("POST", "/volumes/v1/update", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	})
+	_, err = c.VolumeSetName("v1", "billing")
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	// Missing volumes
+	_, err = c.VolumeSetName("v2", "billing")
+	rerr, ok := err.(*RequestError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, rerr.StatusCode == http.StatusNotFound, rerr)
+}
+
+func TestClientVolumeSetTagsHeketi(t *testing.T) {
+	db := tests.Tempfile()
+	defer os.Remove(db)
+
+	// Create the app
+	app := glusterfs.NewTestApp(db)
+	defer app.Close()
+
+	// Setup the server
+	ts := setupHeketiServer(app)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "admin", TEST_ADMIN_KEY)
+	cluster, err := c.ClusterCreate(&api.ClusterCreateRequest{
+		ClusterFlags: api.ClusterFlags{File: true},
+	})
+	tests.Assert(t, err == nil, err)
+	for n := 0; n < 3; n++ {
+		nodeReq := &api.NodeAddRequest{}
+		nodeReq.ClusterId = cluster.Id
+		nodeReq.Hostnames.Manage = []string{fmt.Sprintf("manage%v", n)}
+		nodeReq.Hostnames.Storage = []string{fmt.Sprintf("storage%v", n)}
+		nodeReq.Zone = n + 1
+		node, err := c.NodeAdd(nodeReq)
+		tests.Assert(t, err == nil, err)
+		deviceReq := &api.DeviceAddRequest{}
+		deviceReq.Name = "/dev/sdb"
+		deviceReq.NodeId = node.Id
+		err = c.DeviceAdd(deviceReq)
+		tests.Assert(t, err == nil, err)
+	}
+	volume, err := c.VolumeCreate(&api.VolumeCreateRequest{Size: 10})
+	tests.Assert(t, err == nil, err)
+
+	v, err := c.VolumeSetTags(volume.Id, map[string]string{"owner": "team-a"})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, v.Tags["owner"] == "team-a", v.Tags)
+
+	// Setting the current name leaves the tags alone
+	v, err = c.VolumeSetName(volume.Id, volume.Name)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, v.Tags["owner"] == "team-a", v.Tags)
+
+	// Gluster volumes cannot be renamed
+	_, err = c.VolumeSetName(volume.Id, "billing")
+	_, ok := err.(*InvalidVolumeUpdateError)
+	tests.Assert(t, ok, err)
+
+	v, err = c.VolumeSetTags(volume.Id, nil)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(v.Tags) == 0, v.Tags)
+}
+
+func TestClientOperationProgressStream(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/operations/op1/progress", func(w http.ResponseWriter, r *http.Request) {
+		tests.Assert(t, r.Header.Get("Accept") == "text/event-stream", r.Header)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"state\":\"pending\",\"percent_complete\":10}\n\n")
+		fmt.Fprint(w, "event: progress\ndata: {\"state\":\"pending\",\n")
+		fmt.Fprint(w, "data: \"percent_complete\":60,\"message\":\"moving bricks\"}\n\n")
+		fmt.Fprint(w, "data: {\"state\":\"completed\",\"percent_complete\":100}\n\n")
+	})
+
+	c := NewClientNoAuth(s.URL())
+	events, err := c.OperationProgressStream(context.Background(), "op1", nil)
+	tests.Assert(t, err == nil, err)
+
+	received := []api.OperationProgress{}
+	for event := range events {
+		received = append(received, event)
+	}
+	tests.Assert(t, len(received) == 3, received)
+	tests.Assert(t, received[0].PercentComplete == 10, received)
+	tests.Assert(t, received[1].PercentComplete == 60, received)
+	tests.Assert(t, received[1].Message == "moving bricks", received)
+	tests.Assert(t, received[2].State == api.OperationCompleted, received)
+}
+
+func TestClientOperationProgressStreamPolling(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	// Start an operation to get an id to follow
+	s.HandleAsync("DELETE", "/volumes/v1", &clienttest.AsyncOperation{
+		Pending:         3,
+		PendingProgress: []string{"20", "50%", "working"},
+		PendingBodies:   []string{"", `{"message":"halfway"}`, ""},
+	})
+	req, err := http.NewRequest("DELETE", s.URL()+"/volumes/v1", nil)
+	tests.Assert(t, err == nil, err)
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	r, err := client.Do(req)
+	tests.Assert(t, err == nil, err)
+	r.Body.Close()
+	id := strings.TrimPrefix(r.Header.Get("Location"), ASYNC_ROUTE+"/")
+
+	// The server has no progress stream, so the status is polled
+	c := NewClientNoAuth(s.URL())
+	events, err := c.OperationProgressStream(context.Background(), id,
+		&WaitOptions{PollInterval: time.Millisecond})
+	tests.Assert(t, err == nil, err)
+
+	received := []api.OperationProgress{}
+	for event := range events {
+		received = append(received, event)
+	}
+	tests.Assert(t, len(received) == 4, received)
+	tests.Assert(t, received[0].State == api.OperationPending, received)
+	tests.Assert(t, received[0].PercentComplete == 20, received)
+	tests.Assert(t, received[1].PercentComplete == 50, received)
+	tests.Assert(t, received[1].Message == "halfway", received)
+	tests.Assert(t, received[2].PercentComplete == 0, received)
+	tests.Assert(t, received[3].State == api.OperationCompleted, received)
+
+	// Following an unknown operation reports its failure
+	events, err = c.OperationProgressStream(context.Background(), "unknown",
+		&WaitOptions{PollInterval: time.Millisecond})
+	tests.Assert(t, err == nil, err)
+	event := <-events
+	tests.Assert(t, event.State == api.OperationFailed, event)
+	tests.Assert(t, strings.Contains(event.Message, "Id not found"), event)
+	_, ok := <-events
+	tests.Assert(t, !ok)
+}
+
+func TestClientOperationProgressStreamCancel(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/operations/op1/progress", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"state\":\"pending\",\"percent_complete\":10}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	c := NewClientNoAuth(s.URL())
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.OperationProgressStream(ctx, "op1", nil)
+	tests.Assert(t, err == nil, err)
+
+	event := <-events
+	tests.Assert(t, event.PercentComplete == 10, event)
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		tests.Assert(t, !ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed after cancel")
+	}
+}
+
+func TestClientTokenTTL(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var claims jwt.MapClaims
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {
+		raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		claims = jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+			return []byte("secret"), nil
+		})
+		tests.Assert(t, err == nil, err)
+	})
+	lifetime := func() time.Duration {
+		exp := claims["exp"].(float64)
+		iat := claims["iat"].(float64)
+		return time.Duration(exp-iat) * time.Second
+	}
+
+	// Default lifetime
+	c := NewClient(s.URL(), "admin", "secret")
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, lifetime() == DEFAULT_TOKEN_TTL, lifetime())
+
+	c, err = NewValidatedClient(s.URL(), "admin", "secret",
+		ClientOptions{TokenTTL: time.Minute})
+	tests.Assert(t, err == nil, err)
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, lifetime() == time.Minute, lifetime())
+
+	// Too short and too long lifetimes are rejected when the client is
+	// created, and are never used to sign a token
+	for _, ttl := range []time.Duration{time.Second, 2 * time.Hour} {
+		opts := ClientOptions{TokenTTL: ttl}
+		_, err = NewValidatedClient(s.URL(), "admin", "secret", opts)
+		tests.Assert(t, err != nil)
+		tests.Assert(t, strings.Contains(err.Error(), "outside of the allowed range"), err)
+
+		sent := len(s.Requests())
+		c = NewClientWithOptions(s.URL(), "admin", "secret", opts)
+		err = c.Hello()
+		tests.Assert(t, err != nil)
+		tests.Assert(t, strings.Contains(err.Error(), "outside of the allowed range"), err)
+		tests.Assert(t, len(s.Requests()) == sent)
+	}
+
+	// Validate also reports reserved claims
+	_, err = NewValidatedClient(s.URL(), "admin", "secret",
+		ClientOptions{Claims: map[string]interface{}{"exp": 1}})
+	tests.Assert(t, err != nil)
+}
+
+func TestClientTokenKeyID(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	keys := map[string]string{
+		"2018-01": "oldsecret",
+		"2018-02": "newsecret",
+	}
+	var kid interface{}
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {
+		raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		_, err := jwt.Parse(raw, func(token *jwt.Token) (interface{}, error) {
+			kid = token.Header["kid"]
+			id, _ := kid.(string)
+			key, ok := keys[id]
+			if !ok {
+				return nil, fmt.Errorf("unknown key id %v", kid)
+			}
+			return []byte(key), nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	})
+
+	c := NewClientWithOptions(s.URL(), "admin", "newsecret", ClientOptions{
+		KeyID: "2018-02",
+	})
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, kid == "2018-02", kid)
+
+	// No kid unless configured
+	c = NewClient(s.URL(), "admin", "newsecret")
+	err = c.Hello()
+	tests.Assert(t, err != nil)
+	tests.Assert(t, kid == nil, kid)
+}
+
+func TestClientVolumeVerifyLayout(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	brick := func(id, node string, size uint64) string {
+		return fmt.Sprintf(`{"id":"%v","node":"%v","volume":"v1","size":%v}`,
+			id, node, size)
+	}
+	volume := func(durability string, bricks ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"id":"v1","durability":%v,"bricks":[%v]}`,
+				durability, strings.Join(bricks, ","))
+		}
+	}
+	sets := func(sets string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"sets":%v}`, sets)
+		}
+	}
+	replica3 := `{"type":"replicate","replicate":{"replica":3}}`
+
+	c := NewClientNoAuth(s.URL())
+
+	// Two replica sets over three nodes, the second one added by an
+	// expansion with larger bricks
+	s.Handle("GET", "/volumes/v1", volume(replica3,
+		brick("b1", "n1", 100), brick("b2", "n2", 100), brick("b3", "n3", 100),
+		brick("b4", "n1", 200), brick("b5", "n2", 200), brick("b6", "n3", 200)))
+	s.Handle("GET", "/volumes/v1/bricksets", sets(
+		`[["b1","b2","b3"],["b4","b5","b6"]]`))
+	report, err := c.VolumeVerifyLayout("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, report.Ok(), report.Violations)
+	tests.Assert(t, report.SetSize == 3 && report.Sets == 2, report)
+	tests.Assert(t, report.BricksPerNode["n1"] == 2, report.BricksPerNode)
+
+	// The same bricks grouped so that sizes and nodes clash within sets
+	s.Handle("GET", "/volumes/v1/bricksets", sets(
+		`[["b1","b4","b2"],["b3","b5","b6"]]`))
+	report, err = c.VolumeVerifyLayout("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(report.Violations) == 5, report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[0], "Brick b4 of set 0"), report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[1], "b1 and b4 of set 0"), report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[2], "Brick b5 of set 1"), report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[3], "Brick b6 of set 1"), report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[4], "b3 and b6 of set 1"), report.Violations)
+
+	// Incomplete set, unknown brick and brick outside any set
+	s.Handle("GET", "/volumes/v1", volume(replica3,
+		brick("b1", "n1", 100), brick("b2", "n2", 100), brick("b3", "n3", 100),
+		brick("b4", "n1", 100)))
+	s.Handle("GET", "/volumes/v1/bricksets", sets(
+		`[["b1","b2","b3"],["bx"]]`))
+	report, err = c.VolumeVerifyLayout("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(report.Violations) == 3, report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[0], "Set 1 has 1 bricks"), report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[1], "bx"), report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[2], "b4 is not in any set"), report.Violations)
+
+	// Disperse sets need data + redundancy nodes
+	s.Handle("GET", "/volumes/v1", volume(
+		`{"type":"disperse","disperse":{"data":2,"redundancy":1}}`,
+		brick("b1", "n1", 100), brick("b2", "n2", 100), brick("b3", "n2", 100)))
+	s.Handle("GET", "/volumes/v1/bricksets", sets(`[["b1","b2","b3"]]`))
+	report, err = c.VolumeVerifyLayout("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, report.SetSize == 3, report)
+	tests.Assert(t, len(report.Violations) == 1, report.Violations)
+	tests.Assert(t, strings.Contains(report.Violations[0], "on node n2"), report.Violations)
+
+	// Distribute only volumes may put any brick anywhere
+	s.Handle("GET", "/volumes/v1", volume(`{"type":"none"}`,
+		brick("b1", "n1", 100), brick("b2", "n1", 200)))
+	s.Handle("GET", "/volumes/v1/bricksets", sets(`[["b1"],["b2"]]`))
+	report, err = c.VolumeVerifyLayout("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, report.Ok(), report.Violations)
+	tests.Assert(t, report.Sets == 2, report)
+
+	// Servers that cannot report the sets
+	s.Handle("GET", "/volumes/v1/bricksets", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	})
+	_, err = c.VolumeVerifyLayout("v1")
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	_, err = c.VolumeVerifyLayout("v2")
+	tests.Assert(t, err != nil)
+}
+
+func TestClientNodeDrain(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var lock sync.Mutex
+	drained := false
+	var states []string
+	s.Handle("GET", "/nodes/n1", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		bricks := `[{"id":"b1","size":100},{"id":"b2","size":300}]`
+		if drained {
+			bricks = `[]`
+		}
+		fmt.Fprintf(w, `{"id":"n1","cluster":"c1","state":"online","devices":[`+
+			`{"id":"d1","state":"online","storage":{"total":1000,"used":400,"free":600},"bricks":%v},`+
+			`{"id":"d2","state":"offline","storage":{"total":1000,"used":0,"free":1000},"bricks":[]}]}`,
+			bricks)
+	})
+	s.Handle("GET", "/clusters/c1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","nodes":["n1","n2","n3"],"volumes":[]}`)
+	})
+	n2free := 150
+	s.Handle("GET", "/nodes/n2", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		fmt.Fprintf(w, `{"id":"n2","state":"online","devices":[`+
+			`{"id":"d3","state":"online","storage":{"total":1000,"used":0,"free":%v}}]}`,
+			n2free)
+	})
+	s.Handle("GET", "/nodes/n3", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n3","state":"online","devices":[`+
+			`{"id":"d4","state":"online","storage":{"total":1000,"used":0,"free":200}}]}`)
+	})
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var request api.StateRequest
+			err := utils.GetJsonFromRequest(r, &request)
+			tests.Assert(t, err == nil, err)
+			lock.Lock()
+			states = append(states, name+"="+string(request.State))
+			if name == "d1" && request.State == api.EntryStateFailed {
+				drained = true
+			}
+			lock.Unlock()
+			s.StartAsync(w, r, &clienttest.AsyncOperation{})
+		}
+	}
+	s.Handle("POST", "/nodes/n1/state", record("n1"))
+	s.Handle("POST", "/devices/d1/state", record("d1"))
+	s.Handle("POST", "/devices/d2/state", record("d2"))
+
+	c := NewClientNoAuth(s.URL())
+
+	// Not enough room on the other nodes
+	err := c.NodeDrain("n1", nil)
+	cerr, ok := err.(*InsufficientCapacityError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, cerr.Needed == 400*1024 && cerr.Available == 350*1024, cerr)
+	tests.Assert(t, strings.Contains(err.Error(), "only 358400 bytes are free"), err)
+	tests.Assert(t, len(states) == 0, states)
+
+	// The largest brick does not fit on any other device
+	lock.Lock()
+	n2free = 250
+	lock.Unlock()
+	err = c.NodeDrain("n1", nil)
+	cerr, ok = err.(*InsufficientCapacityError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, cerr.LargestBrick == 300*1024, cerr)
+	tests.Assert(t, strings.Contains(err.Error(), "largest brick"), err)
+	tests.Assert(t, len(states) == 0, states)
+
+	lock.Lock()
+	n2free = 600
+	lock.Unlock()
+	var progress []string
+	err = c.NodeDrain("n1", &NodeDrainOptions{
+		Progress: func(deviceId string, done, total int) {
+			progress = append(progress, fmt.Sprintf("%v %v/%v", deviceId, done, total))
+		},
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, reflect.DeepEqual(states, []string{
+		"n1=offline", "d1=offline", "d1=failed", "d2=failed"}), states)
+	tests.Assert(t, reflect.DeepEqual(progress, []string{"d1 1/2", "d2 2/2"}), progress)
+}
+
+func TestClientMaxPollRequests(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var lock sync.Mutex
+	inflight, maxInflight := 0, 0
+	release := make(chan bool)
+	s.Handle("GET", "/queue/op", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		inflight++
+		if inflight > maxInflight {
+			maxInflight = inflight
+		}
+		lock.Unlock()
+
+		<-release
+
+		lock.Lock()
+		inflight--
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {})
+
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		MaxPollRequests: 1,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.WaitForOperation("/queue/op", nil)
+			tests.Assert(t, err == nil, err)
+		}()
+	}
+
+	// Other requests are not held up by the polls
+	for {
+		lock.Lock()
+		started := inflight
+		lock.Unlock()
+		if started == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+
+	for i := 0; i < 3; i++ {
+		release <- true
+	}
+	wg.Wait()
+	tests.Assert(t, maxInflight == 1, maxInflight)
+}
+
+func TestClientVolumeSplitBrainInfo(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/volumes/v1/heal", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"bricks":[`+
+			`{"id":"b1","pending_heals":3,"split_brain_files":["/b/file","/a/file"]},`+
+			`{"id":"b2","pending_heals":0},`+
+			`{"id":"b3","pending_heals":2,"split_brain_files":["/a/file"]}]}`)
+	})
+	s.Handle("GET", "/volumes/v2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"v2"}`)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	report, err := c.VolumeSplitBrainInfo("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, report.InSplitBrain())
+	tests.Assert(t, reflect.DeepEqual(report.Files, []string{"/a/file", "/b/file"}), report)
+	tests.Assert(t, reflect.DeepEqual(report.AffectedBricks, []string{"b1", "b3"}), report)
+	tests.Assert(t, report.PendingHeals["b1"] == 3, report)
+	tests.Assert(t, report.PendingHeals["b2"] == 0, report)
+	tests.Assert(t, report.PendingHeals["b3"] == 2, report)
+
+	// The volume exists but the server has no heal information
+	_, err = c.VolumeSplitBrainInfo("v2")
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	// Unknown volumes are reported as such
+	_, err = c.VolumeSplitBrainInfo("v3")
+	tests.Assert(t, err != nil && err != ErrNotSupported, err)
+}
+
+func TestClientClusterSetFlagsInUse(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("POST", "/clusters/c1/flags", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "cluster still has block volumes", http.StatusConflict)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	err := c.ClusterSetFlags("c1", &api.ClusterSetFlagsRequest{
+		ClusterFlags: api.ClusterFlags{File: true},
+	})
+	ferr, ok := err.(*ClusterFlagsInUseError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, ferr.ClusterId == "c1", ferr)
+	tests.Assert(t, ferr.Reason == "cluster still has block volumes", ferr)
+}
+
+func TestClientAuthScheme(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {})
+	authorization := func() string {
+		requests := s.Requests()
+		return requests[len(requests)-1].Header.Get("Authorization")
+	}
+
+	for _, test := range []struct {
+		scheme   string
+		expected string
+	}{
+		{"", "Bearer "},
+		{"bearer", "bearer "},
+		{"BEARER", "BEARER "},
+	} {
+		opts := ClientOptions{AuthScheme: test.scheme}
+		tests.Assert(t, opts.Validate() == nil)
+		c := NewClientWithOptions(s.URL(), "admin", "secret", opts)
+		err := c.Hello()
+		tests.Assert(t, err == nil, err)
+		header := authorization()
+		tests.Assert(t, strings.HasPrefix(header, test.expected), header)
+		tests.Assert(t, strings.Count(header, " ") == 1, header)
+	}
+
+	// The scheme must be a single token
+	for _, scheme := range []string{"Bearer x", "Bearer:"} {
+		opts := ClientOptions{AuthScheme: scheme}
+		tests.Assert(t, opts.Validate() != nil, scheme)
+		c := NewClientWithOptions(s.URL(), "admin", "secret", opts)
+		err := c.Hello()
+		tests.Assert(t, err != nil, scheme)
+	}
+}
+
+func TestClientNodesPing(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/clusters/c1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","nodes":["n1","n2"],"volumes":[]}`)
+	})
+	s.Handle("GET", "/nodes/n1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n1","state":"online"}`)
+	})
+	s.Handle("GET", "/nodes/n2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n2","state":"offline"}`)
+	})
+
+	c := NewClientNoAuth(s.URL())
+
+	// Servers which cannot check nodes
+	_, err := c.NodesPing("c1")
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	s.Handle("GET", "/nodes/n1/ping", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n1","up":true,"latency_ms":12}`)
+	})
+	s.Handle("GET", "/nodes/n2/ping", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"n2","up":false,"reason":"connection refused","latency_ms":3000}`)
+	})
+	pings, err := c.NodesPing("c1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(pings) == 2, pings)
+	tests.Assert(t, pings["n1"].Up && pings["n1"].State == api.EntryStateOnline, pings)
+	tests.Assert(t, pings["n1"].Latency == 12*time.Millisecond, pings)
+	tests.Assert(t, !pings["n2"].Up && pings["n2"].Reason == "connection refused", pings)
+	tests.Assert(t, pings["n2"].State == api.EntryStateOffline, pings)
+	tests.Assert(t, pings["n2"].Latency == 3*time.Second, pings)
+}
+
+func TestClientNoAuthSkipsToken(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {})
+	authorization := func() string {
+		requests := s.Requests()
+		return requests[len(requests)-1].Header.Get("Authorization")
+	}
+
+	// No token is signed without credentials, even with options which
+	// would make signing fail
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		Claims: map[string]interface{}{"exp": 1},
+	})
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, authorization() == "", authorization())
+
+	c = NewClientNoAuth(s.URL())
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, authorization() == "", authorization())
+
+	// Clients with credentials still send a token
+	c = NewClient(s.URL(), "admin", "secret")
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, strings.HasPrefix(authorization(), "Bearer "), authorization())
+
+	// As do clients with their own token provider
+	c = NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		TokenProvider: NewJwtTokenProvider("admin", "secret"),
+	})
+	err = c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, authorization() != "")
+}
+
+func TestClientOperationsStatus(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	c := NewClientNoAuth(s.URL())
+
+	// Batch endpoint
+	s.Handle("GET", "/operations/status", func(w http.ResponseWriter, r *http.Request) {
+		tests.Assert(t, reflect.DeepEqual(r.URL.Query()["id"], []string{"1", "2"}),
+			r.URL.Query())
+		fmt.Fprint(w, `{"operations":[`+
+			`{"id":"1","state":"pending"},`+
+			`{"id":"2","state":"completed","location":"/volumes/v1"}]}`)
+	})
+	statuses, err := c.OperationsStatus([]string{"1", "2"})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, statuses["1"].State == api.OperationPending, statuses)
+	tests.Assert(t, statuses["2"].Location == "/volumes/v1", statuses)
+	sent := len(s.Requests())
+	tests.Assert(t, sent == 1, sent)
+
+	// Without the batch endpoint each operation is asked for
+	s.Handle("GET", "/operations/status", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	s.Handle("GET", "/volumes/v1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"v1"}`)
+	})
+	start := func(op *clienttest.AsyncOperation) string {
+		s.HandleAsync("POST", "/start", op)
+		r, err := http.Post(s.URL()+"/start", "", nil)
+		tests.Assert(t, err == nil, err)
+		r.Body.Close()
+		return strings.TrimPrefix(r.Header.Get("Location"), ASYNC_ROUTE+"/")
+	}
+	pending := start(&clienttest.AsyncOperation{Pending: 5})
+	created := start(&clienttest.AsyncOperation{Location: "/volumes/v1"})
+	failed := start(&clienttest.AsyncOperation{
+		StatusCode: http.StatusInternalServerError,
+		Body:       "no space",
+	})
+
+	statuses, err = c.OperationsStatus([]string{pending, created, failed, "99"})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(statuses) == 4, statuses)
+	tests.Assert(t, statuses[pending].State == api.OperationPending, statuses)
+	tests.Assert(t, statuses[created].State == api.OperationCompleted, statuses)
+	tests.Assert(t, statuses[created].Location == s.URL()+"/volumes/v1", statuses)
+	tests.Assert(t, statuses[failed].State == api.OperationFailed, statuses)
+	tests.Assert(t, statuses[failed].Error == "no space", statuses)
+	tests.Assert(t, statuses["99"].State == api.OperationUnknown, statuses)
+
+	// Completed operations are forgotten once read
+	statuses, err = c.OperationsStatus([]string{created})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, statuses[created].State == api.OperationUnknown, statuses)
+}
+
+func TestClientVolumeCreateDryRun(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	c := NewClientNoAuth(s.URL())
+	request := &api.VolumeCreateRequest{}
+	request.Size = 10
+	request.Durability.Type = api.DurabilityReplicate
+	request.Durability.Replicate.Replica = 3
+
+	// Planned by the server
+	s.Handle("POST", "/volumes/plan", func(w http.ResponseWriter, r *http.Request) {
+		var req api.VolumeCreateRequest
+		err := utils.GetJsonFromRequest(r, &req)
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, req.Size == 10, req)
+		fmt.Fprint(w, `{"feasible":true,"cluster":"c1","bricks":[`+
+			`{"set":0,"node":"n1","device":"d1","size":10485760}]}`)
+	})
+	preview, err := c.VolumeCreateDryRun(request)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, preview.Feasible && !preview.Estimated, preview)
+	tests.Assert(t, preview.ClusterId == "c1" && len(preview.Bricks) == 1, preview)
+
+	// Estimated by the client from the topology
+	s.Handle("POST", "/volumes/plan", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	s.Handle("GET", "/clusters", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"clusters":["c1"]}`)
+	})
+	s.Handle("GET", "/clusters/c1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","file":true,"nodes":["n1","n2","n3","n4"],"volumes":[]}`)
+	})
+	var lock sync.Mutex
+	n4state := "offline"
+	for i := 1; i <= 4; i++ {
+		id := fmt.Sprintf("%v", i)
+		s.Handle("GET", "/nodes/n"+id, func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			state := "online"
+			if id == "4" {
+				state = n4state
+			}
+			fmt.Fprintf(w, `{"id":"n%v","state":"%v","devices":[`+
+				`{"id":"d%v","state":"online","storage":{"free":12582912}}]}`,
+				id, state, id)
+		})
+	}
+
+	preview, err = c.VolumeCreateDryRun(request)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, preview.Feasible && preview.Estimated, preview)
+	tests.Assert(t, preview.ClusterId == "c1", preview)
+	tests.Assert(t, len(preview.Bricks) == 3, preview.Bricks)
+	nodes := map[string]bool{}
+	for _, brick := range preview.Bricks {
+		tests.Assert(t, brick.Set == 0 && brick.Size == 10*1024*1024, brick)
+		nodes[brick.NodeId] = true
+	}
+	tests.Assert(t, len(nodes) == 3 && !nodes["n4"], nodes)
+
+	// With snapshot space the bricks of three nodes are too small
+	request.Snapshot.Enable = true
+	preview, err = c.VolumeCreateDryRun(request)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, !preview.Feasible && preview.Estimated, preview)
+
+	lock.Lock()
+	n4state = "online"
+	lock.Unlock()
+	preview, err = c.VolumeCreateDryRun(request)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, preview.Feasible, preview)
+	tests.Assert(t, len(preview.Bricks) == 12, preview.Bricks)
+
+	// Block volumes need a cluster allowing them
+	request.Block = true
+	preview, err = c.VolumeCreateDryRun(request)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, !preview.Feasible && preview.Reason != "", preview)
+}
+
+func TestClientBrickUtilization(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/volumes/v1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"v1","bricks":[`+
+			`{"id":"b1","node":"n1","path":"/bricks/b1"},`+
+			`{"id":"b2","node":"n2","path":"/bricks/b2"},`+
+			`{"id":"b3","node":"n1","path":"/bricks/b3"}]}`)
+	})
+	s.Handle("GET", "/volumes/v1/bricks/utilization", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"bricks":[`+
+			`{"id":"b1","online":true,"bytes_used":100,"bytes_total":1000,`+
+			`"inodes_used":10,"inodes_total":500},`+
+			`{"id":"b2","online":false,"bytes_used":5}]}`)
+	})
+	for _, id := range []string{"n1", "n2"} {
+		id := id
+		s.Handle("GET", "/nodes/"+id, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"id":"%v","hostnames":{"manage":["m-%v"],"storage":["s-%v"]}}`,
+				id, id, id)
+		})
+	}
+	s.Handle("GET", "/volumes/v2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"v2"}`)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	bricks, err := c.BrickUtilization("v1")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(bricks) == 3, bricks)
+	tests.Assert(t, bricks[0].Online && bricks[0].BytesUsed == 100, bricks[0])
+	tests.Assert(t, bricks[0].InodesTotal == 500, bricks[0])
+	tests.Assert(t, bricks[0].Host == "s-n1" && bricks[0].Path == "/bricks/b1", bricks[0])
+
+	// Offline and unreported bricks are marked, without any usage
+	tests.Assert(t, !bricks[1].Online && bricks[1].BytesUsed == 0, bricks[1])
+	tests.Assert(t, bricks[1].Host == "s-n2", bricks[1])
+	tests.Assert(t, bricks[2].Id == "b3" && !bricks[2].Online, bricks[2])
+
+	// The volume exists but the server does not report usage
+	_, err = c.BrickUtilization("v2")
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	_, err = c.BrickUtilization("v3")
+	tests.Assert(t, err != nil && err != ErrNotSupported, err)
+}
+
+func TestClientVolumeDeleteWithGrace(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("POST", "/volumes/v1/delete", func(w http.ResponseWriter, r *http.Request) {
+		var request api.VolumeDeleteScheduleRequest
+		err := utils.GetJsonFromRequest(r, &request)
+		tests.Assert(t, err == nil, err)
+		tests.Assert(t, request.Grace == 3600, request)
+		fmt.Fprint(w, `{"id":"v1","delete_at":"2018-01-02T03:04:05Z"}`)
+	})
+	s.Handle("POST", "/volumes/v1/restore", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.Handle("POST", "/volumes/v2/restore", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "volume v2 was deleted", http.StatusGone)
+	})
+	s.Handle("GET", "/volumes/v3", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"v3"}`)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	at, err := c.VolumeDeleteWithGrace("v1", time.Hour)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, at.Equal(time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)), at)
+
+	err = c.VolumeRestore("v1")
+	tests.Assert(t, err == nil, err)
+	err = c.VolumeRestore("v2")
+	tests.Assert(t, err == ErrRestoreWindowPassed, err)
+
+	_, err = c.VolumeDeleteWithGrace("v1", 0)
+	tests.Assert(t, err != nil, err)
+
+	// The server has no soft deletion, and the volume is not deleted
+	_, err = c.VolumeDeleteWithGrace("v3", time.Minute)
+	tests.Assert(t, err == ErrNotSupported, err)
+	err = c.VolumeRestore("v3")
+	tests.Assert(t, err == ErrNotSupported, err)
+	for _, r := range s.Requests() {
+		tests.Assert(t, r.Method != "DELETE", r)
+	}
+}
+
+func TestClientVolumeWaitHeal(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	var lock sync.Mutex
+	polls := 0
+	s.Handle("GET", "/volumes/v1/heal", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		pending := []int{5, 2, 0}[polls]
+		polls++
+		fmt.Fprintf(w, `{"bricks":[{"id":"b1","pending_heals":%v},`+
+			`{"id":"b2","pending_heals":0}]}`, pending)
+	})
+	s.Handle("GET", "/volumes/v2/heal", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"bricks":[{"id":"b1","pending_heals":5}]}`)
+	})
+	s.Handle("GET", "/volumes/v3/heal", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"bricks":[`+
+			`{"id":"b1","pending_heals":1,"split_brain_files":["/file"]}]}`)
+	})
+
+	c := NewClientNoAuth(s.URL())
+	var progress []uint64
+	report, err := c.VolumeWaitHeal("v1", 0, &VolumeWaitHealOptions{
+		PollInterval: time.Millisecond,
+		Progress: func(report *SplitBrainReport) {
+			progress = append(progress, report.PendingHeals["b1"])
+		},
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, report.Pending() == 0, report)
+	tests.Assert(t, reflect.DeepEqual(progress, []uint64{5, 2, 0}), progress)
+
+	report, err = c.VolumeWaitHeal("v2", 20*time.Millisecond, &VolumeWaitHealOptions{
+		PollInterval: 5 * time.Millisecond,
+	})
+	tests.Assert(t, err == ErrWaitTimeout, err)
+	tests.Assert(t, report.Pending() == 5, report)
+
+	report, err = c.VolumeWaitHeal("v3", 0, nil)
+	tests.Assert(t, err == ErrVolumeInSplitBrain, err)
+	tests.Assert(t, report.InSplitBrain(), report)
+}
+
+func TestClientInterceptors(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/hello", func(w http.ResponseWriter, r *http.Request) {})
+
+	var lock sync.Mutex
+	var order []string
+	named := func(name string) Interceptor {
+		return func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+			lock.Lock()
+			order = append(order, name+" request")
+			lock.Unlock()
+			req.Header.Add("X-Order", name)
+
+			r, err := next(req)
+
+			lock.Lock()
+			order = append(order, name+" response")
+			lock.Unlock()
+			return r, err
+		}
+	}
+	blocked := errors.New("blocked")
+	c := NewClientWithOptions(s.URL(), "", "", ClientOptions{
+		Interceptors: []Interceptor{
+			named("outer"),
+			named("inner"),
+			func(req *http.Request, next RoundTripFunc) (*http.Response, error) {
+				if req.URL.Path == "/volumes" {
+					return nil, blocked
+				}
+				return next(req)
+			},
+		},
+	})
+
+	err := c.Hello()
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, reflect.DeepEqual(order, []string{
+		"outer request", "inner request", "inner response", "outer response"}),
+		order)
+	requests := s.Requests()
+	tests.Assert(t, len(requests) == 1, requests)
+	tests.Assert(t, reflect.DeepEqual(requests[0].Header["X-Order"],
+		[]string{"outer", "inner"}), requests[0].Header)
+
+	// An interceptor may answer the request itself
+	_, err = c.VolumeList()
+	tests.Assert(t, err == blocked, err)
+	tests.Assert(t, len(s.Requests()) == 1)
+}
+
+func TestClientNodeAndDeviceTags(t *testing.T) {
+	db := tests.Tempfile()
+	defer os.Remove(db)
+
+	// Create the app
+	app := glusterfs.NewTestApp(db)
+	defer app.Close()
+
+	// Setup the server
+	ts := setupHeketiServer(app)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "admin", TEST_ADMIN_KEY)
+	cluster, err := c.ClusterCreate(&api.ClusterCreateRequest{
+		ClusterFlags: api.ClusterFlags{File: true},
+	})
+	tests.Assert(t, err == nil, err)
+	nodeReq := &api.NodeAddRequest{}
+	nodeReq.ClusterId = cluster.Id
+	nodeReq.Hostnames.Manage = []string{"manage"}
+	nodeReq.Hostnames.Storage = []string{"storage"}
+	nodeReq.Zone = 1
+	node, err := c.NodeAdd(nodeReq)
+	tests.Assert(t, err == nil, err)
+	deviceReq := &api.DeviceAddRequest{}
+	deviceReq.Name = "/dev/sdb"
+	deviceReq.NodeId = node.Id
+	err = c.DeviceAdd(deviceReq)
+	tests.Assert(t, err == nil, err)
+	node, err = c.NodeInfo(node.Id)
+	tests.Assert(t, err == nil, err)
+	deviceId := node.DevicesInfo[0].Id
+
+	got, err := c.NodeTags(node.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, got != nil && len(got) == 0, got)
+
+	node, err = c.NodeSetTags(node.Id, map[string]string{
+		"zone":             "1",
+		"example.com/rack": "r 12",
+	})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, node.Tags["example.com/rack"] == "r 12", node.Tags)
+	got, err = c.NodeTags(node.Id)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(got) == 2 && got["zone"] == "1", got)
+
+	// Invalid tags are rejected before they are sent
+	for _, bad := range []map[string]string{
+		{"": "x"},
+		{"-zone": "1"},
+		{"zone": "a\nb"},
+	} {
+		_, err = c.NodeSetTags(node.Id, bad)
+		_, ok := err.(*InvalidTagsError)
+		tests.Assert(t, ok, bad, err)
+	}
+
+	device, err := c.DeviceSetTags(deviceId, map[string]string{"disk": "ssd"})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, device.Id == deviceId, device)
+	got, err = c.DeviceTags(deviceId)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, got["disk"] == "ssd", got)
+
+	// Clearing the tags
+	device, err = c.DeviceSetTags(deviceId, nil)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(device.Tags) == 0, device.Tags)
+
+	// Missing entries
+	missing := utils.GenUUID()
+	_, err = c.DeviceTags(missing)
+	nerr, ok := err.(*NotFoundError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, nerr.Kind == "device" && nerr.Id == missing, nerr)
+	_, err = c.NodeSetTags(missing, map[string]string{"zone": "1"})
+	nerr, ok = err.(*NotFoundError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, nerr.Kind == "node" && nerr.Id == missing, nerr)
+}
+
+func TestClientTagsNotSupported(t *testing.T) {
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+
+	s.Handle("GET", "/devices/d1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"d1"}`)
+	})
+	s.Handle("POST", "/devices/d1/tags", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	})
+	s.Handle("POST", "/nodes/n1/tags", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	})
+
+	c := NewClientNoAuth(s.URL())
+
+	// The device exists, so the 404 comes from a server without tags
+	_, err := c.DeviceSetTags("d1", map[string]string{"disk": "ssd"})
+	tests.Assert(t, err == ErrNotSupported, err)
+	_, err = c.NodeSetTags("n1", map[string]string{"zone": "1"})
+	tests.Assert(t, err == ErrNotSupported, err)
+
+	// One request when the set succeeds
+	s.Handle("POST", "/devices/d1/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"d1","tags":{"disk":"ssd"}}`)
+	})
+	sent := len(s.Requests())
+	_, err = c.DeviceSetTags("d1", map[string]string{"disk": "ssd"})
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(s.Requests())-sent == 1, s.Requests()[sent:])
+}
+
+func TestClientVerifyAuth(t *testing.T) {
+	db := tests.Tempfile()
+	defer os.Remove(db)
+
+	app := glusterfs.NewTestApp(db)
+	defer app.Close()
+
+	ts := setupHeketiServer(app)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, "admin", TEST_ADMIN_KEY)
+	err := c.VerifyAuth()
+	tests.Assert(t, err == nil, err)
+
+	c = NewClient(ts.URL, "user", "userkey")
+	err = c.VerifyAuth()
+	tests.Assert(t, err == nil, err)
+
+	c = NewClient(ts.URL, "admin", "badkey")
+	err = c.VerifyAuth()
+	aerr, ok := err.(*AuthError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, aerr.StatusCode == http.StatusUnauthorized, aerr)
+
+	c = NewClientNoAuth(ts.URL)
+	err = c.VerifyAuth()
+	_, ok = err.(*AuthError)
+	tests.Assert(t, ok, err)
+
+	// A server an hour ahead considers the token not valid yet
+	s := clienttest.NewAsyncServer()
+	defer s.Close()
+	s.Handle("GET", "/volumes", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "Required authorization token not found",
+				http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Date",
+			time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		http.Error(w, "Token used before issued", http.StatusUnauthorized)
+	})
+	c = NewClient(s.URL(), "admin", "key")
+	err = c.VerifyAuth()
+	serr, ok := err.(*ClockSkewError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, serr.Skew > 50*time.Minute, serr)
+
+	// Servers without authentication accept any request
+	s.Handle("GET", "/volumes", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"volumes":[]}`)
+	})
+	err = c.VerifyAuth()
+	tests.Assert(t, err == ErrAuthDisabled, err)
+
+	// The checks are never answered from the response cache
+	c = NewClientWithOptions(s.URL(), "admin", "key", ClientOptions{
+		CacheTTL: time.Hour,
+	})
+	for i := 0; i < 2; i++ {
+		sent := len(s.Requests())
+		err = c.VerifyAuth()
+		tests.Assert(t, err == ErrAuthDisabled, err)
+		tests.Assert(t, len(s.Requests()) == sent+1, s.Requests())
+	}
+}
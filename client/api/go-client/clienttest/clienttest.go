@@ -0,0 +1,224 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+// Package clienttest provides a lightweight in-process server which
+// speaks the Heketi asynchronous protocol (202 + Location, polling with
+// X-Pending, 303 See Other on completion) so that client side logic can
+// be tested without running the full Heketi application stack.
+package clienttest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+const (
+	ASYNC_ROUTE = "/queue"
+)
+
+// AsyncOperation describes how the server answers a request which
+// starts an asynchronous operation.
+type AsyncOperation struct {
+	// Number of status polls answered with X-Pending: true before
+	// the operation is considered complete
+	Pending int
+
+	// Optional bodies of the pending status polls, in order
+	PendingBodies []string
+
+	// Optional X-Progress headers of the pending status polls, in order
+	PendingProgress []string
+
+	// If set, the completed operation redirects (303) to this location
+	Location string
+
+	// If Location is not set, the completed operation is answered with
+	// this status and body.  A zero StatusCode means 204 No Content.
+	StatusCode int
+	Body       string
+}
+
+// RecordedRequest is a copy of a request received by the server
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+type pendingOperation struct {
+	op    *AsyncOperation
+	polls int
+}
+
+// AsyncServer is an httptest.Server backed by a scriptable handler
+type AsyncServer struct {
+	Ts *httptest.Server
+
+	lock       sync.Mutex
+	handlers   map[string]http.HandlerFunc
+	operations map[string]*pendingOperation
+	requests   []RecordedRequest
+	nextid     int
+}
+
+// Create a new scriptable server
+//
+// Example:
+//
+//	s := clienttest.NewAsyncServer()
+//	defer s.Close()
+//
+//	s.HandleAsync("DELETE", "/volumes/123", &clienttest.AsyncOperation{
+//		Pending: 2,
+//	})
+//	c := client.NewClientNoAuth(s.URL())
+func NewAsyncServer() *AsyncServer {
+	s := &AsyncServer{
+		handlers:   make(map[string]http.HandlerFunc),
+		operations: make(map[string]*pendingOperation),
+	}
+	s.Ts = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// URL to the test server
+func (s *AsyncServer) URL() string {
+	return s.Ts.URL
+}
+
+// Shutdown the test server
+func (s *AsyncServer) Close() {
+	s.Ts.Close()
+}
+
+// Register a synchronous handler for method and path
+func (s *AsyncServer) Handle(method, path string, h http.HandlerFunc) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.handlers[method+" "+path] = h
+}
+
+// Register a canned asynchronous operation for method and path.  Each
+// request answered with 202 starts a new copy of the operation.
+func (s *AsyncServer) HandleAsync(method, path string, op *AsyncOperation) {
+	s.Handle(method, path, func(w http.ResponseWriter, r *http.Request) {
+		s.StartAsync(w, r, op)
+	})
+}
+
+// Start an asynchronous operation from within a custom handler by
+// answering the request with 202 and the location of the status url
+func (s *AsyncServer) StartAsync(w http.ResponseWriter, r *http.Request,
+	op *AsyncOperation) {
+
+	s.lock.Lock()
+	s.nextid++
+	id := fmt.Sprintf("%v", s.nextid)
+	s.operations[id] = &pendingOperation{op: op}
+	s.lock.Unlock()
+
+	http.Redirect(w, r, ASYNC_ROUTE+"/"+id, http.StatusAccepted)
+}
+
+// Return a copy of all the requests received so far
+func (s *AsyncServer) Requests() []RecordedRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	requests := make([]RecordedRequest, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+func (s *AsyncServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.lock.Lock()
+	s.requests = append(s.requests, RecordedRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: cloneHeader(r.Header),
+		Body:   string(body),
+	})
+	h, ok := s.handlers[r.Method+" "+r.URL.Path]
+	s.lock.Unlock()
+
+	if ok {
+		h(w, r)
+		return
+	}
+
+	if r.Method == "GET" && strings.HasPrefix(r.URL.Path, ASYNC_ROUTE+"/") {
+		s.serveStatus(w, r, strings.TrimPrefix(r.URL.Path, ASYNC_ROUTE+"/"))
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+func (s *AsyncServer) serveStatus(w http.ResponseWriter, r *http.Request, id string) {
+	s.lock.Lock()
+	pending, ok := s.operations[id]
+	if !ok {
+		s.lock.Unlock()
+		http.Error(w, "Id not found", http.StatusNotFound)
+		return
+	}
+	if pending.polls < pending.op.Pending {
+		var body, progress string
+		if pending.polls < len(pending.op.PendingBodies) {
+			body = pending.op.PendingBodies[pending.polls]
+		}
+		if pending.polls < len(pending.op.PendingProgress) {
+			progress = pending.op.PendingProgress[pending.polls]
+		}
+		pending.polls++
+		s.lock.Unlock()
+		w.Header().Add("X-Pending", "true")
+		if progress != "" {
+			w.Header().Set("X-Progress", progress)
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, body)
+		return
+	}
+	delete(s.operations, id)
+	s.lock.Unlock()
+
+	op := pending.op
+	switch {
+	case op.Location != "":
+		http.Redirect(w, r, op.Location, http.StatusSeeOther)
+	case op.StatusCode == 0:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(op.StatusCode)
+		fmt.Fprint(w, op.Body)
+	}
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
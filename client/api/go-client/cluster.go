@@ -49,7 +49,7 @@ func (c *Client) ClusterCreate(request *api.ClusterCreateRequest) (*api.ClusterI
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusCreated {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -62,6 +62,20 @@ func (c *Client) ClusterCreate(request *api.ClusterCreateRequest) (*api.ClusterI
 	return &cluster, nil
 }
 
+// Returned by ClusterSetFlags when the cluster still has volumes of a
+// type the new flags would disable
+type ClusterFlagsInUseError struct {
+	ClusterId string
+	Reason    string
+}
+
+func (e *ClusterFlagsInUseError) Error() string {
+	return e.Reason
+}
+
+// ClusterSetFlags sets whether the cluster may be used for file and for
+// block volumes. Servers refuse to disable a volume type the cluster
+// still has volumes of, which is returned as a *ClusterFlagsInUseError.
 func (c *Client) ClusterSetFlags(id string, request *api.ClusterSetFlagsRequest) error {
 
 	buffer, err := json.Marshal(request)
@@ -89,13 +103,30 @@ func (c *Client) ClusterSetFlags(id string, request *api.ClusterSetFlagsRequest)
 		return err
 	}
 	defer r.Body.Close()
+	if r.StatusCode == http.StatusConflict {
+		return &ClusterFlagsInUseError{
+			ClusterId: id,
+			Reason:    responseError(r).Error(),
+		}
+	}
 	if r.StatusCode != http.StatusOK {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
 }
 
+// ClusterFlags returns whether the cluster may be used for file and for
+// block volumes
+func (c *Client) ClusterFlags(id string) (*api.ClusterFlags, error) {
+	cluster, err := c.ClusterInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	flags := cluster.ClusterFlags
+	return &flags, nil
+}
+
 func (c *Client) ClusterInfo(id string) (*api.ClusterInfoResponse, error) {
 
 	// Create request
@@ -117,7 +148,7 @@ func (c *Client) ClusterInfo(id string) (*api.ClusterInfoResponse, error) {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -151,7 +182,7 @@ func (c *Client) ClusterList() (*api.ClusterListResponse, error) {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -185,7 +216,7 @@ func (c *Client) ClusterDelete(id string) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
@@ -15,8 +15,6 @@ package client
 import (
 	"io/ioutil"
 	"net/http"
-
-	"github.com/heketi/heketi/pkg/utils"
 )
 
 // DbDump provides a JSON representation of current state of DB
@@ -39,7 +37,7 @@ func (c *Client) DbDump() (string, error) {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return "", utils.GetErrorFromResponse(r)
+		return "", responseError(r)
 	}
 
 	respBytes, err := ioutil.ReadAll(r.Body)
@@ -15,7 +15,12 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/heketi/heketi/pkg/glusterfs/api"
@@ -49,7 +54,7 @@ func (c *Client) DeviceAdd(request *api.DeviceAddRequest) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Wait for response
@@ -58,44 +63,52 @@ func (c *Client) DeviceAdd(request *api.DeviceAddRequest) error {
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
 }
 
 func (c *Client) DeviceInfo(id string) (*api.DeviceInfoResponse, error) {
+	device, _, err := c.DeviceInfoWithETag(id)
+	return device, err
+}
+
+// DeviceInfoWithETag returns the device information together with the
+// ETag the server sent for it, or an empty string if the server does not
+// send ETags. The ETag can be passed to DeviceStateIfMatch.
+func (c *Client) DeviceInfoWithETag(id string) (*api.DeviceInfoResponse, string, error) {
 
 	// Create request
 	req, err := http.NewRequest("GET", c.host+"/devices/"+id, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Set token
 	err = c.setToken(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Get info
 	r, err := c.do(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, "", responseError(r)
 	}
 
 	// Read JSON response
 	var device api.DeviceInfoResponse
 	err = utils.GetJsonFromResponse(r, &device)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return &device, nil
+	return &device, r.Header.Get("ETag"), nil
 }
 
 func (c *Client) DeviceDelete(id string) error {
@@ -119,7 +132,7 @@ func (c *Client) DeviceDelete(id string) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Wait for response
@@ -128,7 +141,7 @@ func (c *Client) DeviceDelete(id string) error {
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
@@ -136,6 +149,19 @@ func (c *Client) DeviceDelete(id string) error {
 
 func (c *Client) DeviceState(id string,
 	request *api.StateRequest) error {
+	return c.DeviceStateIfMatch(id, request, "")
+}
+
+// DeviceStateIfMatch changes the state of the device only if it has not
+// changed since etag was read using DeviceInfoWithETag. If the device
+// changed a *PreconditionFailedError is returned. An empty etag makes
+// the request unconditional.
+//
+// The server must compare If-Match to the current ETag of the device and
+// answer 412 Precondition Failed on mismatch before starting the
+// operation. Servers which ignore If-Match apply the change unconditionally.
+func (c *Client) DeviceStateIfMatch(id string,
+	request *api.StateRequest, etag string) error {
 
 	// Marshal request to JSON
 	buffer, err := json.Marshal(request)
@@ -151,6 +177,7 @@ func (c *Client) DeviceState(id string,
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	setIfMatch(req, etag)
 
 	// Set token
 	err = c.setToken(req)
@@ -164,8 +191,11 @@ func (c *Client) DeviceState(id string,
 		return err
 	}
 	defer r.Body.Close()
+	if r.StatusCode == http.StatusPreconditionFailed {
+		return preconditionFailed(r, etag)
+	}
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Wait for response
@@ -174,7 +204,7 @@ func (c *Client) DeviceState(id string,
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
@@ -201,7 +231,7 @@ func (c *Client) DeviceResync(id string) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Wait for response
@@ -210,8 +240,185 @@ func (c *Client) DeviceResync(id string) error {
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
 }
+
+// Result of adding one device with DevicesAdd
+type DeviceAddResult struct {
+	NodeId string
+	Name   string
+
+	// Set if the node already had the device, in which case it was not
+	// added again
+	Skipped bool
+
+	// Number of times adding the device was tried
+	Attempts int
+	Err      error
+}
+
+// Options for DevicesAdd
+type DevicesAddOptions struct {
+	// Maximum number of devices added at the same time,
+	// DEFAULT_BULK_CONCURRENCY if not set
+	Concurrency int
+
+	// Number of times a device is tried when adding it fails with a
+	// transient error, 3 if not set
+	MaxAttempts int
+
+	// Time between attempts, one second if not set
+	RetryDelay time.Duration
+
+	// If set, called each time a device is finished with its result
+	// and the number of devices finished so far. Calls are never made
+	// concurrently.
+	Progress func(result *DeviceAddResult, done, total int)
+}
+
+// DevicesAdd adds many devices at the same time and waits for all of
+// them. Devices which their node already has are skipped, and adding a
+// device is retried when it fails because the server could not be
+// reached or was temporarily unavailable. Before each retry the node is
+// read again, and a device which the failed attempt added anyway is not
+// sent a second time. The results are returned in
+// the same order as the requests. If any device could not be added a
+// BulkError keyed by "<node id>:<device name>" is returned as well.
+func (c *Client) DevicesAdd(requests []*api.DeviceAddRequest,
+	opts *DevicesAddOptions) ([]*DeviceAddResult, error) {
+
+	if opts == nil {
+		opts = &DevicesAddOptions{}
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	delay := opts.RetryDelay
+	if delay == 0 {
+		delay = time.Second
+	}
+
+	// Find the devices each node already has
+	nodeIds := []string{}
+	existing := make(map[string]map[string]bool)
+	for _, request := range requests {
+		if _, ok := existing[request.NodeId]; !ok {
+			existing[request.NodeId] = make(map[string]bool)
+			nodeIds = append(nodeIds, request.NodeId)
+		}
+	}
+	err := forEachConcurrentErr(len(nodeIds), opts.Concurrency,
+		func(i int) error {
+			node, err := c.NodeInfo(nodeIds[i])
+			if err != nil {
+				return err
+			}
+			for _, device := range node.DevicesInfo {
+				existing[nodeIds[i]][device.Name] = true
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+	done := 0
+	results := make([]*DeviceAddResult, len(requests))
+	errs := BulkError{}
+	forEachConcurrent(len(requests), opts.Concurrency, func(i int) {
+		request := requests[i]
+		result := &DeviceAddResult{
+			NodeId: request.NodeId,
+			Name:   request.Name,
+		}
+
+		if existing[request.NodeId][request.Name] {
+			result.Skipped = true
+		} else {
+			for {
+				result.Attempts++
+				result.Err = c.DeviceAdd(request)
+				if result.Err == nil || result.Attempts >= maxAttempts ||
+					!isTransientError(result.Err) {
+					break
+				}
+				if c.sleep(delay) != nil {
+					break
+				}
+
+				// The failed attempt may have added the device anyway.
+				// If the node cannot be read, the last error stands.
+				found, err := c.nodeHasDevice(request.NodeId, request.Name)
+				if err != nil {
+					break
+				}
+				if found {
+					result.Err = nil
+					break
+				}
+			}
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		results[i] = result
+		if result.Err != nil {
+			errs[request.NodeId+":"+request.Name] = result.Err
+		}
+		done++
+		if opts.Progress != nil {
+			opts.Progress(result, done, len(requests))
+		}
+	})
+
+	if len(errs) != 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+// Returns true if the node has a device with the given name
+func (c *Client) nodeHasDevice(nodeId, name string) (bool, error) {
+	node, err := c.NodeInfo(nodeId)
+	if err != nil {
+		return false, err
+	}
+	for _, device := range node.DevicesInfo {
+		if device.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Errors which are expected to go away when a request is tried again:
+// the request timed out, the connection was refused or reset, or the
+// server answered that it is temporarily unable to handle the request
+func isTransientError(err error) bool {
+	switch e := err.(type) {
+	case *url.Error:
+		return isTransientError(e.Err)
+	case *net.OpError:
+		return e.Timeout() || isTransientError(e.Err)
+	case *os.SyscallError:
+		return isTransientError(e.Err)
+	case syscall.Errno:
+		return e == syscall.ECONNREFUSED || e == syscall.ECONNRESET
+	case net.Error:
+		return e.Timeout()
+	case *RequestError:
+		switch e.StatusCode {
+		case http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+// Returned by CheckAPIVersion when the server does not support the API
+// version the client was configured with
+type APIVersionError struct {
+	Requested int
+	Supported []int
+}
+
+func (e *APIVersionError) Error() string {
+	return fmt.Sprintf("Server does not support API version %v, "+
+		"supported versions are %v", e.Requested, e.Supported)
+}
+
+func apiMediaType(version int) string {
+	return fmt.Sprintf("application/vnd.heketi.v%v+json", version)
+}
+
+// ServerInfo returns the version of the server and the API versions it
+// supports. ErrNotSupported is returned if the server does not report
+// this information.
+func (c *Client) ServerInfo() (*api.ServerInfoResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/info", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, ErrNotSupported
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var info api.ServerInfoResponse
+	err = utils.GetJsonFromResponse(r, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// CheckAPIVersion verifies that the server supports the API version set
+// in ClientOptions.APIVersion, returning an APIVersionError if it does
+// not. Servers which do not report their supported versions only
+// provide version 1 of the API.
+func (c *Client) CheckAPIVersion() error {
+	if c.opts.APIVersion == 0 {
+		return nil
+	}
+
+	supported := []int{1}
+	info, err := c.ServerInfo()
+	switch {
+	case err == ErrNotSupported:
+	case err != nil:
+		return err
+	case len(info.APIVersions) != 0:
+		supported = info.APIVersions
+	}
+
+	for _, version := range supported {
+		if version == c.opts.APIVersion {
+			return nil
+		}
+	}
+	return &APIVersionError{
+		Requested: c.opts.APIVersion,
+		Supported: supported,
+	}
+}
@@ -0,0 +1,53 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"net/http"
+)
+
+// Sends a request and returns its response
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Interceptor adds behavior around the requests of a client, such as
+// logging, metrics or extra headers, when set in
+// ClientOptions.Interceptors. It may inspect or change the request,
+// call next to send it, and inspect or change the response, or answer
+// the request itself without calling next. Interceptors must be safe
+// for concurrent use.
+//
+// Only the response cache and tracing are built as interceptors, inside
+// the ones set in the options. Throttling, the circuit breaker and the
+// clock skew check stay in the final send, so every interceptor runs
+// before the request takes a throttle slot and cannot be placed inside
+// it. The token is not part of the chain either: the request already
+// carries its Authorization header, signed for its method and URL, so
+// an interceptor which changes them must set the header itself. The
+// client does not retry requests within the chain; an interceptor which
+// calls next more than once sends the request again each time.
+type Interceptor func(req *http.Request, next RoundTripFunc) (*http.Response, error)
+
+// Chain the interceptors in front of send. The first interceptor sees
+// the request first and the response last.
+func chainInterceptors(interceptors []Interceptor,
+	send RoundTripFunc) RoundTripFunc {
+
+	next := send
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := interceptors[i], next
+		next = func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, inner)
+		}
+	}
+	return next
+}
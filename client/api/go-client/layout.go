@@ -0,0 +1,190 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+// Result of VolumeVerifyLayout
+type LayoutReport struct {
+	VolumeId   string
+	Durability api.DurabilityType
+
+	// Number of bricks in each replica or disperse set, 1 for
+	// distribute only volumes
+	SetSize int
+
+	// Number of bricks and of sets found
+	Bricks int
+	Sets   int
+
+	// Number of bricks placed on each node
+	BricksPerNode map[string]int
+
+	// Problems found, empty if the layout matches the durability
+	Violations []string
+}
+
+// Returns true if the layout matches the durability of the volume
+func (r *LayoutReport) Ok() bool {
+	return len(r.Violations) == 0
+}
+
+// VolumeVerifyLayout checks that the bricks of the volume match its
+// durability: every set has as many bricks as the durability asks for,
+// the bricks of a set have the same size and are on different nodes,
+// and all bricks belong to the volume. Bricks of different sets may
+// differ in size, as they do after an expansion. The sets are read
+// from the server, which asks gluster for them; servers that cannot
+// report them return ErrNotSupported. The volume is only read, never
+// changed.
+func (c *Client) VolumeVerifyLayout(id string) (*LayoutReport, error) {
+	volume, err := c.VolumeInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	sets, err := c.volumeBrickSets(id)
+	if err != nil {
+		return nil, err
+	}
+	return verifyLayout(volume, sets), nil
+}
+
+func (c *Client) volumeBrickSets(id string) ([][]string, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/"+id+"/bricksets", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get sets
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, c.volumeNotSupported(id)
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var sets api.VolumeBrickSetsResponse
+	err = utils.GetJsonFromResponse(r, &sets)
+	if err != nil {
+		return nil, err
+	}
+
+	return sets.Sets, nil
+}
+
+func verifyLayout(volume *api.VolumeInfoResponse, sets [][]string) *LayoutReport {
+	report := &LayoutReport{
+		VolumeId:      volume.Id,
+		Durability:    volume.Durability.Type,
+		Bricks:        len(volume.Bricks),
+		Sets:          len(sets),
+		BricksPerNode: make(map[string]int),
+	}
+	violation := func(format string, args ...interface{}) {
+		report.Violations = append(report.Violations, fmt.Sprintf(format, args...))
+	}
+
+	durability := volume.Durability
+	switch durability.Type {
+	case api.DurabilityReplicate:
+		report.SetSize = durability.Replicate.Replica
+	case api.DurabilityEC:
+		report.SetSize = durability.Disperse.Data + durability.Disperse.Redundancy
+	case api.DurabilityDistributeOnly, "":
+		report.SetSize = 1
+	default:
+		violation("Unknown durability type %v", durability.Type)
+		return report
+	}
+	if report.SetSize < 1 {
+		violation("Durability %v does not give the number of bricks per set",
+			durability.Type)
+		return report
+	}
+
+	if report.Bricks == 0 {
+		violation("Volume has no bricks")
+		return report
+	}
+
+	bricks := make(map[string]api.BrickInfo, len(volume.Bricks))
+	for _, brick := range volume.Bricks {
+		bricks[brick.Id] = brick
+		report.BricksPerNode[brick.NodeId]++
+		if brick.VolumeId != volume.Id {
+			violation("Brick %v belongs to volume %v", brick.Id, brick.VolumeId)
+		}
+	}
+
+	placed := make(map[string]bool, len(volume.Bricks))
+	for i, set := range sets {
+		if len(set) != report.SetSize {
+			violation("Set %v has %v bricks, expected %v",
+				i, len(set), report.SetSize)
+		}
+
+		var first *api.BrickInfo
+		nodes := make(map[string]string, len(set))
+		for _, id := range set {
+			brick, ok := bricks[id]
+			if !ok {
+				violation("Set %v has brick %v which is not in the volume", i, id)
+				continue
+			}
+			if placed[id] {
+				violation("Brick %v is in more than one set", id)
+			}
+			placed[id] = true
+
+			if first == nil {
+				first = &brick
+			} else if brick.Size != first.Size {
+				violation("Brick %v of set %v has size %v KB, brick %v has %v KB",
+					id, i, brick.Size, first.Id, first.Size)
+			}
+			if other, ok := nodes[brick.NodeId]; ok {
+				violation("Bricks %v and %v of set %v are both on node %v",
+					other, id, i, brick.NodeId)
+			}
+			nodes[brick.NodeId] = id
+		}
+	}
+
+	for _, brick := range volume.Bricks {
+		if !placed[brick.Id] {
+			violation("Brick %v is not in any set", brick.Id)
+		}
+	}
+
+	return report
+}
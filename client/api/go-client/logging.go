@@ -72,7 +72,7 @@ func (c *Client) LogLevelSet(request *api.LogLevelInfo) error {
 		return err
 	}
 	if r.StatusCode != http.StatusOK {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 	return nil
 }
@@ -15,6 +15,7 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -50,7 +51,7 @@ func (c *Client) NodeAdd(request *api.NodeAddRequest) (*api.NodeInfoResponse, er
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Wait for response
@@ -59,7 +60,7 @@ func (c *Client) NodeAdd(request *api.NodeAddRequest) (*api.NodeInfoResponse, er
 		return nil, err
 	}
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -93,7 +94,7 @@ func (c *Client) NodeInfo(id string) (*api.NodeInfoResponse, error) {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -127,7 +128,7 @@ func (c *Client) NodeDelete(id string) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Wait for response
@@ -136,7 +137,7 @@ func (c *Client) NodeDelete(id string) error {
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
@@ -171,7 +172,7 @@ func (c *Client) NodeState(id string, request *api.StateRequest) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Wait for response
@@ -180,8 +181,258 @@ func (c *Client) NodeState(id string, request *api.StateRequest) error {
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
 }
+
+// Options for NodeDrain
+type NodeDrainOptions struct {
+	// If set, called after each device of the node was emptied with the
+	// number of devices emptied so far and the total
+	Progress func(deviceId string, done, total int)
+}
+
+// Returned by NodeDrain when the other nodes of the cluster do not have
+// room for the bricks of the node. Sizes are in bytes.
+type InsufficientCapacityError struct {
+	NodeId string
+
+	// Space used on the node and free on the other nodes
+	Needed    uint64
+	Available uint64
+
+	// Largest brick on the node and largest free space on a single
+	// device of the other nodes
+	LargestBrick uint64
+	LargestFree  uint64
+}
+
+func (e *InsufficientCapacityError) Error() string {
+	if e.Needed > e.Available {
+		return fmt.Sprintf("Cannot drain node %v: it uses %v bytes but "+
+			"only %v bytes are free on the other nodes",
+			e.NodeId, e.Needed, e.Available)
+	}
+	return fmt.Sprintf("Cannot drain node %v: its largest brick of %v "+
+		"bytes does not fit on any device of the other nodes, which have "+
+		"at most %v bytes free", e.NodeId, e.LargestBrick, e.LargestFree)
+}
+
+// NodeDrain moves all bricks off the node so that it can be
+// decommissioned. The node is set offline, so that no new bricks are
+// placed on it, and then each of its devices is removed, which makes the
+// server replace its bricks with bricks on other nodes. NodeDrain
+// returns once the node holds no bricks; the node is left offline with
+// its devices in the failed state, ready to be deleted.
+//
+// Before changing anything, NodeDrain checks that the online devices of
+// the other online nodes of the cluster have room for the bricks and
+// returns an *InsufficientCapacityError if they do not. The server
+// applies further placement rules, so a drain can still fail after it
+// started.
+func (c *Client) NodeDrain(id string, opts *NodeDrainOptions) error {
+	if opts == nil {
+		opts = &NodeDrainOptions{}
+	}
+
+	node, err := c.NodeInfo(id)
+	if err != nil {
+		return err
+	}
+	err = c.checkDrainCapacity(node)
+	if err != nil {
+		return err
+	}
+
+	if node.State == api.EntryStateOnline {
+		err = c.NodeState(id, &api.StateRequest{State: api.EntryStateOffline})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, device := range node.DevicesInfo {
+		if device.State == api.EntryStateOnline {
+			err = c.DeviceState(device.Id,
+				&api.StateRequest{State: api.EntryStateOffline})
+			if err != nil {
+				return err
+			}
+		}
+		if len(device.Bricks) != 0 || device.State != api.EntryStateFailed {
+			err = c.DeviceState(device.Id,
+				&api.StateRequest{State: api.EntryStateFailed})
+			if err != nil {
+				return err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(device.Id, i+1, len(node.DevicesInfo))
+		}
+	}
+
+	// Confirm the server moved everything
+	node, err = c.NodeInfo(id)
+	if err != nil {
+		return err
+	}
+	for _, device := range node.DevicesInfo {
+		if len(device.Bricks) != 0 {
+			return fmt.Errorf("Device %v of node %v still holds %v bricks "+
+				"after draining", device.Id, id, len(device.Bricks))
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) checkDrainCapacity(node *api.NodeInfoResponse) error {
+	capacity := &InsufficientCapacityError{NodeId: node.Id}
+	for _, device := range node.DevicesInfo {
+		capacity.Needed += device.Storage.Used * 1024
+		for _, brick := range device.Bricks {
+			if brick.Size*1024 > capacity.LargestBrick {
+				capacity.LargestBrick = brick.Size * 1024
+			}
+		}
+	}
+	if capacity.Needed == 0 && capacity.LargestBrick == 0 {
+		return nil
+	}
+
+	cluster, err := c.ClusterInfo(node.ClusterId)
+	if err != nil {
+		return err
+	}
+	others := make([]string, 0, len(cluster.Nodes))
+	for _, other := range cluster.Nodes {
+		if other != node.Id {
+			others = append(others, other)
+		}
+	}
+	infos := make([]*api.NodeInfoResponse, len(others))
+	err = forEachConcurrentErr(len(others), DEFAULT_BULK_CONCURRENCY, func(i int) error {
+		var err error
+		infos[i], err = c.NodeInfo(others[i])
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, other := range infos {
+		if other.State != api.EntryStateOnline {
+			continue
+		}
+		for _, device := range other.DevicesInfo {
+			if device.State != api.EntryStateOnline {
+				continue
+			}
+			free := device.Storage.Free * 1024
+			capacity.Available += free
+			if free > capacity.LargestFree {
+				capacity.LargestFree = free
+			}
+		}
+	}
+
+	if capacity.Needed > capacity.Available ||
+		capacity.LargestBrick > capacity.LargestFree {
+		return capacity
+	}
+	return nil
+}
+
+// Reachability of a node as checked by the server
+type NodePingResult struct {
+	// State of the node in Heketi. Nodes which are not online are
+	// checked as well.
+	State api.EntryState
+
+	// Whether glusterd answered on the node, and if not why
+	Up     bool
+	Reason string
+
+	// Time the server took to check the node
+	Latency time.Duration
+}
+
+// NodesPing asks the server to check that it can reach glusterd on
+// every node of the cluster, whatever the state of the node, and
+// returns the results by node id. The nodes are checked with at most
+// DEFAULT_BULK_CONCURRENCY requests in flight. It returns
+// ErrNotSupported if the server cannot check nodes.
+func (c *Client) NodesPing(clusterId string) (map[string]NodePingResult, error) {
+	cluster, err := c.ClusterInfo(clusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]NodePingResult, len(cluster.Nodes))
+	err = forEachConcurrentErr(len(cluster.Nodes), DEFAULT_BULK_CONCURRENCY,
+		func(i int) error {
+			node, err := c.NodeInfo(cluster.Nodes[i])
+			if err != nil {
+				return err
+			}
+			ping, err := c.nodePing(cluster.Nodes[i])
+			if err != nil {
+				return err
+			}
+			results[i] = NodePingResult{
+				State:   node.State,
+				Up:      ping.Up,
+				Reason:  ping.Reason,
+				Latency: time.Duration(ping.Latency) * time.Millisecond,
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	pings := make(map[string]NodePingResult, len(cluster.Nodes))
+	for i, id := range cluster.Nodes {
+		pings[id] = results[i]
+	}
+	return pings, nil
+}
+
+func (c *Client) nodePing(id string) (*api.NodePingResponse, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/nodes/"+id+"/ping", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, ErrNotSupported
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var ping api.NodePingResponse
+	err = utils.GetJsonFromResponse(r, &ping)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ping, nil
+}
@@ -0,0 +1,411 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+// OperationLog returns the server side event trail for the operation
+// with the given id. The id is the X-Request-ID of the request which
+// started the operation. A NotFoundError is returned if the server does
+// not know the operation, and ErrNotSupported if the server does not
+// provide operation logs.
+func (c *Client) OperationLog(id string) ([]api.OperationEvent, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/operations/"+id+"/log", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Request-ID", id)
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, c.operationNotSupported(id, r)
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var oplog api.OperationLogResponse
+	err = utils.GetJsonFromResponse(r, &oplog)
+	if err != nil {
+		return nil, err
+	}
+
+	return oplog.Events, nil
+}
+
+// Servers answer with 404 both for unknown operations and for endpoints
+// they do not provide. Servers which list operations know about them, so
+// a 404 from those is for an unknown operation.
+func (c *Client) operationNotSupported(id string, r *http.Response) error {
+	if r.StatusCode != http.StatusNotFound {
+		return ErrNotSupported
+	}
+	err := responseError(r)
+	_, lerr := c.operationsListPage(url.Values{"limit": []string{"1"}})
+	if lerr != nil {
+		return lerr
+	}
+	return &NotFoundError{Kind: "operation", Id: id, Err: err}
+}
+
+// Filter for OperationsList
+type OperationsFilter struct {
+	// Only list operations in this state, such as api.OperationPending.
+	// All operations are listed if not set.
+	State string
+
+	// Number of operations requested from the server at a time. The
+	// server default is used if not set.
+	PageSize int
+}
+
+// OperationsList returns the operations known to the server, following
+// the server's pagination until all matching operations are read.
+// ErrNotSupported is returned if the server does not list operations.
+func (c *Client) OperationsList(filter *OperationsFilter) ([]api.OperationInfo, error) {
+	if filter == nil {
+		filter = &OperationsFilter{}
+	}
+
+	operations := []api.OperationInfo{}
+	marker := ""
+	for {
+		query := url.Values{}
+		if filter.State != "" {
+			query.Set("state", filter.State)
+		}
+		if filter.PageSize > 0 {
+			query.Set("limit", strconv.Itoa(filter.PageSize))
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		page, err := c.operationsListPage(query)
+		if err != nil {
+			return nil, err
+		}
+
+		operations = append(operations, page.Operations...)
+		if page.Next == "" || page.Next == marker {
+			return operations, nil
+		}
+		marker = page.Next
+	}
+}
+
+func (c *Client) operationsListPage(query url.Values) (*api.OperationListResponse, error) {
+
+	// Create request
+	u := c.host + "/operations"
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, ErrNotSupported
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var page api.OperationListResponse
+	err = utils.GetJsonFromResponse(r, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+const (
+	// Route on the server where the status of operations is kept
+	ASYNC_ROUTE = "/queue"
+)
+
+var (
+	ErrWaitTimeout = errors.New("Timed out waiting for the operation to complete")
+)
+
+// Options for waiting on an asynchronous operation
+type WaitOptions struct {
+	// Time between status polls, one second if not set
+	PollInterval time.Duration
+
+	// Maximum time to wait, no limit if not set. When waiting on many
+	// operations the deadline is shared by all of them.
+	Timeout time.Duration
+}
+
+func (opts *WaitOptions) interval() time.Duration {
+	if opts == nil || opts.PollInterval == 0 {
+		return time.Second
+	}
+	return opts.PollInterval
+}
+
+func (opts *WaitOptions) deadline() time.Time {
+	if opts == nil || opts.Timeout == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(opts.Timeout)
+}
+
+// OperationLocation returns the status location of the operation with
+// the given id, for callers which persisted the id rather than the
+// location reported through ClientOptions.OperationStarted
+func (c *Client) OperationLocation(id string) string {
+	return c.host + ASYNC_ROUTE + "/" + id
+}
+
+// WaitForOperation resumes waiting for an asynchronous operation which
+// was started earlier, possibly by another process, given its status
+// location. On success it returns the URL of the resource the operation
+// created or changed, or an empty string if the operation has no
+// resulting resource (for example a delete). The status of a completed
+// operation can only be read once, after which the server forgets it.
+func (c *Client) WaitForOperation(location string, opts *WaitOptions) (string, error) {
+	return c.waitForOperation(location, opts.interval(), opts.deadline())
+}
+
+// WaitForOperations waits for many asynchronous operations at the same
+// time, so that the total wait is that of the slowest operation rather
+// than the sum of all of them. The results are returned in the same
+// order as the locations. If any operation failed or did not complete
+// before the deadline a BulkError keyed by location is returned along
+// with the results of the operations which succeeded.
+func (c *Client) WaitForOperations(locations []string,
+	opts *WaitOptions) ([]string, error) {
+
+	interval := opts.interval()
+	deadline := opts.deadline()
+
+	var lock sync.Mutex
+	results := make([]string, len(locations))
+	errs := BulkError{}
+	forEachConcurrent(len(locations), len(locations), func(i int) {
+		url, err := c.waitForOperation(locations[i], interval, deadline)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			errs[locations[i]] = err
+			return
+		}
+		results[i] = url
+	})
+
+	if len(errs) != 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+func (c *Client) waitForOperation(location string,
+	interval time.Duration, deadline time.Time) (string, error) {
+
+	if strings.HasPrefix(location, "/") {
+		location = c.host + location
+	}
+
+	r, err := c.pollOperationUntil(location, interval, deadline, nil)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+
+	switch r.StatusCode {
+	case http.StatusNoContent:
+		return "", nil
+	case http.StatusOK:
+		// The completed operation redirected to its resource
+		if r.Request.URL.String() != location {
+			return r.Request.URL.String(), nil
+		}
+		return "", nil
+	default:
+		return "", responseError(r)
+	}
+}
+
+// OperationsStatus returns the status of many asynchronous operations,
+// by operation id, in a single request to the server. Servers without
+// a batch status endpoint are asked for the status of each operation
+// instead, with at most DEFAULT_BULK_CONCURRENCY requests in flight.
+// Note that those servers forget a completed operation once its status
+// was read, so only the first caller learns that it completed; later
+// callers, like callers passing an id the server never knew, get the
+// api.OperationUnknown state.
+//
+// Operations whose status could not be read are left out of the result
+// and reported in a BulkError keyed by operation id.
+func (c *Client) OperationsStatus(ids []string) (map[string]api.OperationStatus, error) {
+	statuses := make(map[string]api.OperationStatus, len(ids))
+	if len(ids) == 0 {
+		return statuses, nil
+	}
+
+	batch, err := c.operationsStatusBatch(ids)
+	if err == nil {
+		for _, status := range batch.Operations {
+			statuses[status.Id] = status
+		}
+		return statuses, nil
+	}
+	if err != ErrNotSupported {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+	errs := BulkError{}
+	forEachConcurrent(len(ids), DEFAULT_BULK_CONCURRENCY, func(i int) {
+		status, err := c.operationStatus(ids[i])
+
+		lock.Lock()
+		defer lock.Unlock()
+		if err != nil {
+			errs[ids[i]] = err
+			return
+		}
+		statuses[ids[i]] = *status
+	})
+
+	if len(errs) != 0 {
+		return statuses, errs
+	}
+	return statuses, nil
+}
+
+func (c *Client) operationsStatusBatch(ids []string) (*api.OperationsStatusResponse, error) {
+
+	// Create request
+	query := url.Values{"id": ids}
+	req, err := http.NewRequest("GET",
+		c.host+"/operations/status?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, ErrNotSupported
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var batch api.OperationsStatusResponse
+	err = utils.GetJsonFromResponse(r, &batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+// Read the status of a single operation from its status location
+func (c *Client) operationStatus(id string) (*api.OperationStatus, error) {
+	location := c.OperationLocation(id)
+
+	// Create request
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(contextWithPoll(req.Context()))
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get status
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	status := &api.OperationStatus{Id: id}
+	switch {
+	case r.StatusCode == http.StatusNotFound:
+		status.State = api.OperationUnknown
+	case r.StatusCode >= http.StatusBadRequest:
+		status.State = api.OperationFailed
+		status.Error = responseError(r).Error()
+	case r.Header.Get("X-Pending") == "true":
+		status.State = api.OperationPending
+	default:
+		status.State = api.OperationCompleted
+		// The completed operation redirected to its resource
+		if r.Request.URL.String() != location {
+			status.Location = r.Request.URL.String()
+		}
+	}
+
+	return status, nil
+}
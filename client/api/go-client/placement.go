@@ -0,0 +1,263 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+// Server defaults used to estimate a placement when the server cannot
+// plan one. Servers may be configured with other limits. Sizes in KB.
+const (
+	estimateBrickMinSize   = uint64(1024 * 1024)
+	estimateBrickMaxSize   = uint64(4 * 1024 * 1024 * 1024)
+	estimateBrickMaxNum    = 32
+	estimateReplica        = 2
+	estimateECData         = 4
+	estimateECRedundancy   = 2
+	estimateSnapshotFactor = 1.5
+)
+
+// Result of VolumeCreateDryRun
+type PlacementPreview struct {
+	api.VolumePlacement
+
+	// Set if the plan was estimated by the client because the server
+	// cannot plan volumes. The estimate uses the default brick limits
+	// of the server and only simple placement rules, so the server may
+	// still decide differently.
+	Estimated bool
+}
+
+// VolumeCreateDryRun reports whether the volume create request would
+// succeed and which bricks it would create, without creating anything.
+// The server is asked to plan the volume; servers which cannot plan
+// volumes get the plan estimated by the client from the topology,
+// using only online devices of online nodes.
+func (c *Client) VolumeCreateDryRun(request *api.VolumeCreateRequest) (
+	*PlacementPreview, error) {
+
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST", c.host+"/volumes/plan",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return c.estimatePlacement(request)
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var preview PlacementPreview
+	err = utils.GetJsonFromResponse(r, &preview.VolumePlacement)
+	if err != nil {
+		return nil, err
+	}
+
+	return &preview, nil
+}
+
+func (c *Client) estimatePlacement(request *api.VolumeCreateRequest) (
+	*PlacementPreview, error) {
+
+	topo, err := c.TopologyInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	// Bricks per set and the number of them holding data
+	setSize, data := 1, 1
+	switch request.Durability.Type {
+	case api.DurabilityReplicate:
+		setSize = request.Durability.Replicate.Replica
+		if setSize == 0 {
+			setSize = estimateReplica
+		}
+	case api.DurabilityEC:
+		data = request.Durability.Disperse.Data
+		if data == 0 {
+			data = estimateECData
+		}
+		redundancy := request.Durability.Disperse.Redundancy
+		if redundancy == 0 {
+			redundancy = estimateECRedundancy
+		}
+		setSize = data + redundancy
+	}
+
+	// Space taken on a device for every KB of brick
+	factor := 1.0
+	if request.Snapshot.Enable {
+		factor = float64(request.Snapshot.Factor)
+		if factor == 0 {
+			factor = estimateSnapshotFactor
+		}
+	}
+
+	// Clusters the volume may be placed on
+	wanted := map[string]bool{}
+	for _, id := range request.Clusters {
+		wanted[id] = true
+	}
+	clusters := []api.Cluster{}
+	for _, cluster := range topo.ClusterList {
+		if len(wanted) != 0 && !wanted[cluster.Id] {
+			continue
+		}
+		if (request.Block && !cluster.Block) || (!request.Block && !cluster.File) {
+			continue
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	preview := &PlacementPreview{Estimated: true}
+	if len(clusters) == 0 {
+		preview.Reason = "No cluster can hold the volume"
+		return preview, nil
+	}
+
+	// Like the server, use more and smaller bricks until they fit
+	size := uint64(request.Size) * 1024 * 1024
+	for sets := 1; ; sets *= 2 {
+		brick := size / uint64(sets) / uint64(data)
+		if brick < estimateBrickMinSize {
+			preview.Reason = fmt.Sprintf("No space for the volume, even "+
+				"with bricks of the minimum size of %v KB", estimateBrickMinSize)
+			return preview, nil
+		}
+		if brick > estimateBrickMaxSize {
+			continue
+		}
+		if sets*setSize > estimateBrickMaxNum {
+			preview.Reason = fmt.Sprintf("No space for the volume with at "+
+				"most %v bricks", estimateBrickMaxNum)
+			return preview, nil
+		}
+
+		need := uint64(float64(brick) * factor)
+		for _, cluster := range clusters {
+			bricks := placeBricks(&cluster, sets, setSize, brick, need)
+			if bricks != nil {
+				preview.Feasible = true
+				preview.ClusterId = cluster.Id
+				preview.Bricks = bricks
+				return preview, nil
+			}
+		}
+	}
+}
+
+// Place each set on setSize different nodes, using the device with the
+// most free space of the nodes with the most free space. Returns nil if
+// the bricks do not fit.
+func placeBricks(cluster *api.Cluster, sets, setSize int,
+	brick, need uint64) []api.BrickPlacement {
+
+	devices := placementDevices{}
+	for _, node := range cluster.Nodes {
+		if node.State != api.EntryStateOnline {
+			continue
+		}
+		for _, d := range node.DevicesInfo {
+			if d.State != api.EntryStateOnline {
+				continue
+			}
+			devices = append(devices, &placementDevice{
+				node: node.Id,
+				id:   d.Id,
+				free: d.Storage.Free,
+			})
+		}
+	}
+
+	bricks := []api.BrickPlacement{}
+	for set := 0; set < sets; set++ {
+		sort.Sort(devices)
+		used := map[string]bool{}
+		placed := 0
+		for _, d := range devices {
+			if placed == setSize {
+				break
+			}
+			if used[d.node] || d.free < need {
+				continue
+			}
+			used[d.node] = true
+			d.free -= need
+			placed++
+			bricks = append(bricks, api.BrickPlacement{
+				Set:      set,
+				NodeId:   d.node,
+				DeviceId: d.id,
+				Size:     brick,
+			})
+		}
+		if placed < setSize {
+			return nil
+		}
+	}
+	return bricks
+}
+
+type placementDevice struct {
+	node string
+	id   string
+	free uint64
+}
+
+// Sorts devices with the most free space first, ties broken by id
+type placementDevices []*placementDevice
+
+func (p placementDevices) Len() int      { return len(p) }
+func (p placementDevices) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p placementDevices) Less(i, j int) bool {
+	if p[i].free != p[j].free {
+		return p[i].free > p[j].free
+	}
+	return p[i].id < p[j].id
+}
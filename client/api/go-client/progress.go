@@ -0,0 +1,225 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+)
+
+// OperationProgressStream follows the progress of the asynchronous
+// operation with the given id. Events are read from the server's
+// event stream at /operations/{id}/progress if it provides one.
+// Otherwise, or if the stream breaks before the operation finished, the
+// status location of the operation is polled instead and an event is
+// synthesized for every poll.
+//
+// The last event has the state api.OperationCompleted or
+// api.OperationFailed, after which the channel is closed. The channel
+// is also closed, without a final event, once ctx is done or the
+// Timeout in opts has passed. Only PollInterval and Timeout of opts are
+// used.
+func (c *Client) OperationProgressStream(ctx context.Context, id string,
+	opts *WaitOptions) (<-chan api.OperationProgress, error) {
+
+	cancel := func() {}
+	if opts != nil && opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	}
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/operations/"+id+"/progress", nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Open the stream
+	r, err := c.do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stream := r.StatusCode == http.StatusOK && isEventStream(r)
+	if !stream {
+		r.Body.Close()
+	}
+
+	events := make(chan api.OperationProgress)
+	go func() {
+		defer cancel()
+		defer close(events)
+
+		if stream {
+			finished := readProgressEvents(ctx, r, events)
+			r.Body.Close()
+			if finished {
+				return
+			}
+		}
+		c.pollProgress(ctx, c.OperationLocation(id), opts.interval(), events)
+	}()
+
+	return events, nil
+}
+
+func isEventStream(r *http.Response) bool {
+	mediatype, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediatype == "text/event-stream"
+}
+
+// Send a progress event unless ctx is done first
+func sendProgress(ctx context.Context, events chan<- api.OperationProgress,
+	event api.OperationProgress) bool {
+
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func isFinished(event api.OperationProgress) bool {
+	return event.State == api.OperationCompleted ||
+		event.State == api.OperationFailed
+}
+
+// Forward the events of a server-sent event stream, each carrying an
+// api.OperationProgress as JSON in its data lines. Returns true if the
+// operation finished or ctx is done, false if the stream ended early.
+func readProgressEvents(ctx context.Context, r *http.Response,
+	events chan<- api.OperationProgress) bool {
+
+	var data []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			continue
+		}
+		if line != "" || len(data) == 0 {
+			// Other fields and comments are not used
+			continue
+		}
+
+		var event api.OperationProgress
+		err := json.Unmarshal([]byte(strings.Join(data, "\n")), &event)
+		data = nil
+		if err != nil {
+			// Skip events this client does not understand
+			continue
+		}
+		if !sendProgress(ctx, events, event) || isFinished(event) {
+			return true
+		}
+	}
+	return ctx.Err() != nil
+}
+
+// Poll the status location of an operation, synthesizing a progress
+// event for every poll. The percentage is taken from a numeric
+// X-Progress header, or from the body of the pending status if it
+// reports one.
+func (c *Client) pollProgress(ctx context.Context, location string,
+	interval time.Duration, events chan<- api.OperationProgress) {
+
+	for {
+		event, err := c.pollProgressOnce(ctx, location)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			event = api.OperationProgress{
+				State:   api.OperationFailed,
+				Message: err.Error(),
+			}
+		}
+		if !sendProgress(ctx, events, event) || isFinished(event) {
+			return
+		}
+
+		// A closed client fails the next poll, which ends the stream
+		select {
+		case <-time.After(interval):
+		case <-c.ctx.Done():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) pollProgressOnce(ctx context.Context,
+	location string) (api.OperationProgress, error) {
+
+	var event api.OperationProgress
+
+	// Create request
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return event, err
+	}
+	req = req.WithContext(contextWithPoll(ctx))
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return event, err
+	}
+
+	// Get status
+	r, err := c.do(req)
+	if err != nil {
+		return event, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode >= http.StatusBadRequest {
+		return event, responseError(r)
+	}
+
+	if r.Header.Get("X-Pending") != "true" {
+		event.State = api.OperationCompleted
+		event.PercentComplete = 100
+		return event, nil
+	}
+
+	// Pending status bodies are free form, only use them if they
+	// happen to be progress
+	if r.ContentLength != 0 {
+		json.NewDecoder(r.Body).Decode(&event)
+	}
+	event.State = api.OperationPending
+	progress := strings.TrimSuffix(r.Header.Get("X-Progress"), "%")
+	if percent, err := strconv.Atoi(progress); err == nil {
+		event.PercentComplete = percent
+	}
+	return event, nil
+}
@@ -0,0 +1,160 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/heketi/heketi/pkg/glusterfs/api"
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+// Returned by the tag methods when a tag key or value is not valid
+type InvalidTagsError struct {
+	Reason string
+}
+
+func (e *InvalidTagsError) Error() string {
+	return "Invalid tags: " + e.Reason
+}
+
+// NodeTags returns the tags of the node
+func (c *Client) NodeTags(id string) (map[string]string, error) {
+	node, err := c.NodeInfo(id)
+	if err != nil {
+		return nil, notFound("node", id, err)
+	}
+	return nonNilTags(node.Tags), nil
+}
+
+// NodeSetTags replaces the tags of the node and returns the node as
+// stored by the server
+func (c *Client) NodeSetTags(id string, tags map[string]string) (
+	*api.NodeInfoResponse, error) {
+
+	var node api.NodeInfoResponse
+	err := c.setTags("/nodes/"+id+"/tags", tags, &node)
+	if err == errTagsNotFound {
+		_, err = c.NodeInfo(id)
+		if err != nil {
+			return nil, notFound("node", id, err)
+		}
+		return nil, ErrNotSupported
+	} else if err != nil {
+		return nil, err
+	}
+	if !tagsEqual(node.Tags, tags) {
+		return &node, fmt.Errorf("Tags of node %v were not updated", id)
+	}
+	return &node, nil
+}
+
+// DeviceTags returns the tags of the device
+func (c *Client) DeviceTags(id string) (map[string]string, error) {
+	device, err := c.DeviceInfo(id)
+	if err != nil {
+		return nil, notFound("device", id, err)
+	}
+	return nonNilTags(device.Tags), nil
+}
+
+// DeviceSetTags replaces the tags of the device and returns the device
+// as stored by the server
+func (c *Client) DeviceSetTags(id string, tags map[string]string) (
+	*api.DeviceInfoResponse, error) {
+
+	var device api.DeviceInfoResponse
+	err := c.setTags("/devices/"+id+"/tags", tags, &device)
+	if err == errTagsNotFound {
+		_, err = c.DeviceInfo(id)
+		if err != nil {
+			return nil, notFound("device", id, err)
+		}
+		return nil, ErrNotSupported
+	} else if err != nil {
+		return nil, err
+	}
+	if !tagsEqual(device.Tags, tags) {
+		return &device, fmt.Errorf("Tags of device %v were not updated", id)
+	}
+	return &device, nil
+}
+
+// Returned by setTags on a 404, which is sent both for a missing entry
+// and by servers without tag support
+var errTagsNotFound = errors.New("tags not found")
+
+// Replace the tags at path and read the updated entry into info
+func (c *Client) setTags(path string, tags map[string]string,
+	info interface{}) error {
+
+	request := &api.TagsChangeRequest{Tags: nonNilTags(tags)}
+	err := request.Validate()
+	if err != nil {
+		return &InvalidTagsError{Reason: err.Error()}
+	}
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST", c.host+path, bytes.NewBuffer(buffer))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	switch r.StatusCode {
+	case http.StatusOK:
+		return utils.GetJsonFromResponse(r, info)
+	case http.StatusBadRequest:
+		return &InvalidTagsError{Reason: responseError(r).Error()}
+	case http.StatusNotFound:
+		return errTagsNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrNotSupported
+	default:
+		return responseError(r)
+	}
+}
+
+func nonNilTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return map[string]string{}
+	}
+	return tags
+}
+
+func tagsEqual(a, b map[string]string) bool {
+	return reflect.DeepEqual(nonNilTags(a), nonNilTags(b))
+}
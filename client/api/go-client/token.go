@@ -0,0 +1,197 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// TokenProvider supplies the Authorization header value for requests.
+// It is called for every request the client sends, including status
+// polls and redirects, so implementations should cache tokens they
+// cannot mint cheaply. Implementations must be safe for concurrent use.
+type TokenProvider interface {
+	// Returns the Authorization header value, including the scheme,
+	// for a request with the given method and URL path
+	Token(method, path string) (string, error)
+}
+
+// Lifetime of the tokens signed by the JWT token provider. Tokens which
+// expire too soon are rejected by servers whose clock is slightly off or
+// expire before a slow request is read, while long lived tokens are a
+// risk if leaked and are rejected by servers limiting token lifetime.
+const (
+	DEFAULT_TOKEN_TTL = 5 * time.Minute
+	MIN_TOKEN_TTL     = 10 * time.Second
+	MAX_TOKEN_TTL     = time.Hour
+)
+
+// Authorization scheme of the tokens signed by the JWT token provider.
+// The Heketi server accepts the scheme in any case, but gateways
+// enforcing RFC 6750 only accept the canonical casing.
+const (
+	DEFAULT_AUTH_SCHEME = "Bearer"
+)
+
+// Claims set by the JWT token provider itself which cannot be given
+// as custom claims. The audience is set through its own option.
+var reservedClaims = []string{"iss", "iat", "exp", "qsh", "aud"}
+
+// Default provider which signs a JWT with HS256 using a shared key
+type jwtTokenProvider struct {
+	user     string
+	key      string
+	audience string
+	claims   map[string]interface{}
+	ttl      time.Duration
+	keyID    string
+	scheme   string
+
+	// Set if the client options the provider was created with are
+	// invalid, returned by every call to Token
+	err error
+}
+
+// Create a provider which signs a JWT for the given user with the given
+// shared key as expected by the Heketi JWT middleware
+func NewJwtTokenProvider(user, key string) TokenProvider {
+	return &jwtTokenProvider{
+		user:   user,
+		key:    key,
+		ttl:    DEFAULT_TOKEN_TTL,
+		scheme: DEFAULT_AUTH_SCHEME,
+	}
+}
+
+// Like NewJwtTokenProvider, but the tokens also carry the audience, if
+// not empty, and the given custom claims. An error is returned if any
+// of the custom claims would replace a claim set by the provider.
+func NewJwtTokenProviderWithClaims(user, key, audience string,
+	claims map[string]interface{}) (TokenProvider, error) {
+
+	err := validateClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJwtTokenProvider(user, key, audience, claims, 0), nil
+}
+
+func newJwtTokenProvider(user, key, audience string,
+	claims map[string]interface{}, ttl time.Duration) *jwtTokenProvider {
+
+	if ttl == 0 {
+		ttl = DEFAULT_TOKEN_TTL
+	}
+	j := &jwtTokenProvider{
+		user:     user,
+		key:      key,
+		audience: audience,
+		claims:   make(map[string]interface{}, len(claims)),
+		ttl:      ttl,
+		scheme:   DEFAULT_AUTH_SCHEME,
+	}
+	for name, value := range claims {
+		j.claims[name] = value
+	}
+	return j
+}
+
+func validateTokenTTL(ttl time.Duration) error {
+	if ttl == 0 {
+		return nil
+	}
+	if ttl < MIN_TOKEN_TTL || ttl > MAX_TOKEN_TTL {
+		return fmt.Errorf("Token lifetime %v is outside of the allowed "+
+			"range of %v to %v", ttl, MIN_TOKEN_TTL, MAX_TOKEN_TTL)
+	}
+	return nil
+}
+
+// The scheme must be a single token as defined by RFC 7235
+func validateAuthScheme(scheme string) error {
+	if scheme == "" {
+		return nil
+	}
+	for _, r := range scheme {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+			return fmt.Errorf("Invalid authorization scheme %q", scheme)
+		}
+	}
+	return nil
+}
+
+func validateClaims(claims map[string]interface{}) error {
+	for _, reserved := range reservedClaims {
+		if _, ok := claims[reserved]; ok {
+			return fmt.Errorf("Claim %v is reserved and cannot be set "+
+				"as a custom claim", reserved)
+		}
+	}
+	return nil
+}
+
+// Create JSON Web Token
+func (j *jwtTokenProvider) Token(method, path string) (string, error) {
+
+	// The client options were checked when the provider was created
+	if j.err != nil {
+		return "", j.err
+	}
+
+	// Create qsh hash
+	qshstring := method + "&" + path
+	hash := sha256.New()
+	hash.Write([]byte(qshstring))
+
+	// Create Token
+	claims := jwt.MapClaims{
+		// Set issuer
+		"iss": j.user,
+
+		// Set issued at time
+		"iat": time.Now().Unix(),
+
+		// Set expiration
+		"exp": time.Now().Add(j.ttl).Unix(),
+
+		// Set qsh
+		"qsh": hex.EncodeToString(hash.Sum(nil)),
+	}
+	if j.audience != "" {
+		claims["aud"] = j.audience
+	}
+
+	// Custom claims never replace the ones above, see validateClaims
+	for name, value := range j.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if j.keyID != "" {
+		token.Header["kid"] = j.keyID
+	}
+
+	// Sign the token
+	signedtoken, err := token.SignedString([]byte(j.key))
+	if err != nil {
+		return "", err
+	}
+
+	return j.scheme + " " + signedtoken, nil
+}
@@ -16,20 +16,61 @@ import (
 	"github.com/heketi/heketi/pkg/glusterfs/api"
 )
 
+// TopologyInfo returns every cluster together with its volumes and its
+// nodes, including the devices of each node. The information is fetched
+// with at most DEFAULT_BULK_CONCURRENCY requests in flight at a time.
 func (c *Client) TopologyInfo() (*api.TopologyInfoResponse, error) {
-	topo := &api.TopologyInfoResponse{
-		ClusterList: make([]api.Cluster, 0),
-	}
 	clusterlist, err := c.ClusterList()
 	if err != nil {
 		return nil, err
 	}
-	for _, cluster := range clusterlist.Clusters {
-		clusteri, err := c.ClusterInfo(cluster)
-		if err != nil {
-			return nil, err
+
+	// Get the information of every cluster
+	clusters := make([]*api.ClusterInfoResponse, len(clusterlist.Clusters))
+	err = forEachConcurrentErr(len(clusters), DEFAULT_BULK_CONCURRENCY,
+		func(i int) (err error) {
+			clusters[i], err = c.ClusterInfo(clusterlist.Clusters[i])
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the volumes and nodes of all the clusters together
+	type item struct {
+		cluster int
+		volume  string
+		node    string
+	}
+	items := []item{}
+	for i, clusteri := range clusters {
+		for _, volume := range clusteri.Volumes {
+			items = append(items, item{cluster: i, volume: volume})
+		}
+		for _, node := range clusteri.Nodes {
+			items = append(items, item{cluster: i, node: node})
 		}
-		cluster := api.Cluster{
+	}
+	volumes := make([]*api.VolumeInfoResponse, len(items))
+	nodes := make([]*api.NodeInfoResponse, len(items))
+	err = forEachConcurrentErr(len(items), DEFAULT_BULK_CONCURRENCY,
+		func(i int) (err error) {
+			if items[i].volume != "" {
+				volumes[i], err = c.VolumeInfo(items[i].volume)
+			} else {
+				nodes[i], err = c.NodeInfo(items[i].node)
+			}
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	topo := &api.TopologyInfoResponse{
+		ClusterList: make([]api.Cluster, len(clusters)),
+	}
+	for i, clusteri := range clusters {
+		topo.ClusterList[i] = api.Cluster{
 			Id:      clusteri.Id,
 			Volumes: make([]api.VolumeInfoResponse, 0),
 			Nodes:   make([]api.NodeInfoResponse, 0),
@@ -38,29 +79,17 @@ func (c *Client) TopologyInfo() (*api.TopologyInfoResponse, error) {
 				File:  clusteri.File,
 			},
 		}
-		cluster.Id = clusteri.Id
-
-		// Iterate over the volume list in the cluster
-		for _, volumes := range clusteri.Volumes {
-			volumesi, err := c.VolumeInfo(volumes)
-			if err != nil {
-				return nil, err
-			}
-			if volumesi.Cluster == cluster.Id {
-				cluster.Volumes = append(cluster.Volumes, *volumesi)
-			}
-		}
-
-		// Iterate over the nodes in the cluster
-		for _, node := range clusteri.Nodes {
-			nodei, err := c.NodeInfo(string(node))
-			if err != nil {
-				return nil, err
+	}
+	for i, it := range items {
+		cluster := &topo.ClusterList[it.cluster]
+		if volumes[i] != nil {
+			if volumes[i].Cluster == cluster.Id {
+				cluster.Volumes = append(cluster.Volumes, *volumes[i])
 			}
-			cluster.Nodes = append(cluster.Nodes, *nodei)
+		} else {
+			cluster.Nodes = append(cluster.Nodes, *nodes[i])
 		}
-		topo.ClusterList = append(topo.ClusterList, cluster)
 	}
-	return topo, nil
 
+	return topo, nil
 }
@@ -0,0 +1,76 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), as published by the Free Software Foundation,
+// or under the Apache License, Version 2.0 <LICENSE-APACHE2 or
+// http://www.apache.org/licenses/LICENSE-2.0>.
+//
+// You may not use this file except in compliance with those terms.
+//
+
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer creates the spans the client records when ClientOptions.Tracer
+// is set. It is a small interface so that the client does not depend on
+// a tracing library; an adapter for OpenTelemetry or another tracing
+// system implements it. Implementations must be safe for concurrent use.
+//
+// The client starts a span for every request it sends, named
+// "heketi <method> <path>", and a span named "heketi wait <path>" while
+// waiting for an asynchronous operation. The status polls of a wait are
+// children of the wait span, and every pending poll is recorded as a
+// "pending" event on it.
+type Tracer interface {
+	// Start a span. parent is nil for spans which are not started
+	// within another span of the client.
+	StartSpan(name string, parent Span) Span
+}
+
+// Span is a single timed operation started by a Tracer
+type Span interface {
+	// Add the headers which propagate the trace context to the server
+	Inject(header http.Header)
+
+	SetAttribute(key, value string)
+	AddEvent(name string, attributes map[string]string)
+
+	// Finish the span. err is the error the operation failed with, or nil.
+	End(err error)
+}
+
+type spanKey struct{}
+
+func contextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func spanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanKey{}).(Span)
+	return span
+}
+
+// Start a span for a wait on an asynchronous operation, nil if tracing
+// is not enabled
+func (c *Client) startWaitSpan(location string) Span {
+	if c.opts.Tracer == nil {
+		return nil
+	}
+	span := c.opts.Tracer.StartSpan("heketi wait "+pathOf(location), nil)
+	span.SetAttribute("heketi.operation_location", location)
+	return span
+}
+
+func pathOf(location string) string {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return location
+	}
+	return req.URL.Path
+}
@@ -15,7 +15,13 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/heketi/heketi/pkg/glusterfs/api"
@@ -53,7 +59,7 @@ func (c *Client) VolumeCreate(request *api.VolumeCreateRequest) (
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Wait for response
@@ -62,7 +68,7 @@ func (c *Client) VolumeCreate(request *api.VolumeCreateRequest) (
 		return nil, err
 	}
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -106,7 +112,7 @@ func (c *Client) VolumeExpand(id string, request *api.VolumeExpandRequest) (
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Wait for response
@@ -115,7 +121,7 @@ func (c *Client) VolumeExpand(id string, request *api.VolumeExpandRequest) (
 		return nil, err
 	}
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -150,7 +156,7 @@ func (c *Client) VolumeList() (*api.VolumeListResponse, error) {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -163,6 +169,44 @@ func (c *Client) VolumeList() (*api.VolumeListResponse, error) {
 	return &volumes, nil
 }
 
+// VolumeListDetailed returns the information of every volume, fetching
+// the volumes using at most concurrency requests at a time. If any of
+// the volumes cannot be fetched the volumes which could be fetched are
+// returned together with a BulkError describing the failures.
+func (c *Client) VolumeListDetailed(concurrency int) ([]*api.VolumeInfoResponse, error) {
+	list, err := c.VolumeList()
+	if err != nil {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+	infos := make([]*api.VolumeInfoResponse, len(list.Volumes))
+	errs := BulkError{}
+	forEachConcurrent(len(list.Volumes), concurrency, func(i int) {
+		info, err := c.VolumeInfo(list.Volumes[i])
+		if err != nil {
+			lock.Lock()
+			errs[list.Volumes[i]] = err
+			lock.Unlock()
+			return
+		}
+		infos[i] = info
+	})
+
+	// Keep the order of the volume list
+	volumes := make([]*api.VolumeInfoResponse, 0, len(infos))
+	for _, info := range infos {
+		if info != nil {
+			volumes = append(volumes, info)
+		}
+	}
+	if len(errs) != 0 {
+		return volumes, errs
+	}
+
+	return volumes, nil
+}
+
 func (c *Client) VolumeInfo(id string) (*api.VolumeInfoResponse, error) {
 
 	// Create request
@@ -184,7 +228,7 @@ func (c *Client) VolumeInfo(id string) (*api.VolumeInfoResponse, error) {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusOK {
-		return nil, utils.GetErrorFromResponse(r)
+		return nil, responseError(r)
 	}
 
 	// Read JSON response
@@ -218,7 +262,7 @@ func (c *Client) VolumeDelete(id string) error {
 	}
 	defer r.Body.Close()
 	if r.StatusCode != http.StatusAccepted {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	// Wait for response
@@ -227,8 +271,900 @@ func (c *Client) VolumeDelete(id string) error {
 		return err
 	}
 	if r.StatusCode != http.StatusNoContent {
-		return utils.GetErrorFromResponse(r)
+		return responseError(r)
 	}
 
 	return nil
 }
+
+var (
+	// Returned by VolumeRestore once the volume has been deleted
+	ErrRestoreWindowPassed = errors.New("The volume can no longer be restored, its grace period has passed")
+)
+
+// VolumeDeleteWithGrace schedules the volume to be deleted once grace
+// has passed, and returns the time of the deletion. Until then the
+// deletion can be cancelled with VolumeRestore. Grace is rounded up to
+// whole seconds.
+//
+// The server accepts the deletion with POST /volumes/{id}/delete and an
+// api.VolumeDeleteScheduleRequest, answering with an
+// api.VolumeDeleteScheduleResponse. Servers without soft deletion
+// answer with 404 Not Found or 405 Method Not Allowed, in which case
+// ErrNotSupported is returned and the volume is left untouched.
+func (c *Client) VolumeDeleteWithGrace(id string, grace time.Duration) (
+	time.Time, error) {
+
+	request := &api.VolumeDeleteScheduleRequest{
+		Grace: int((grace + time.Second - 1) / time.Second),
+	}
+	err := request.Validate()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST", c.host+"/volumes/"+id+"/delete",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return time.Time{}, c.volumeNotSupported(id)
+	}
+	if r.StatusCode != http.StatusOK {
+		return time.Time{}, responseError(r)
+	}
+
+	// Read JSON response
+	var schedule api.VolumeDeleteScheduleResponse
+	err = utils.GetJsonFromResponse(r, &schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, schedule.DeleteAt)
+}
+
+// VolumeRestore cancels a deletion scheduled by VolumeDeleteWithGrace.
+// It returns ErrRestoreWindowPassed if the grace period has passed and
+// the volume was deleted.
+//
+// The server restores the volume with POST /volumes/{id}/restore and
+// answers with 410 Gone for volumes which it already deleted after
+// their grace period.
+func (c *Client) VolumeRestore(id string) error {
+
+	// Create a request
+	req, err := http.NewRequest("POST", c.host+"/volumes/"+id+"/restore", nil)
+	if err != nil {
+		return err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	switch {
+	case r.StatusCode == http.StatusOK || r.StatusCode == http.StatusNoContent:
+		return nil
+	case r.StatusCode == http.StatusGone:
+		return ErrRestoreWindowPassed
+	case isNotSupported(r):
+		return c.volumeNotSupported(id)
+	default:
+		return responseError(r)
+	}
+}
+
+// Servers answer with 404 both for missing volumes and for endpoints
+// they do not provide. Returns the error of reading the volume if it
+// does not exist, or ErrNotSupported.
+func (c *Client) volumeNotSupported(id string) error {
+	_, err := c.VolumeInfo(id)
+	if err != nil {
+		return err
+	}
+	return ErrNotSupported
+}
+
+var (
+	ErrRebalanceInProgress = errors.New("A rebalance is already in progress on the volume")
+	ErrNothingToRebalance  = errors.New("The volume has nothing to rebalance")
+)
+
+// Options for VolumeRebalance
+type VolumeRebalanceOptions struct {
+	// Time between status polls, one second if not set
+	PollInterval time.Duration
+
+	// If set, called with the progress reported by every status poll
+	// while the rebalance is running
+	Progress func(status *api.VolumeRebalanceStatus)
+}
+
+// VolumeRebalance starts a rebalance of the volume, normally needed
+// after the volume was expanded, and waits for it to finish. It returns
+// ErrRebalanceInProgress if the volume is already being rebalanced,
+// ErrNothingToRebalance if the server found no data to move and
+// ErrNotSupported if the server cannot rebalance volumes.
+func (c *Client) VolumeRebalance(id string, opts *VolumeRebalanceOptions) (
+	*api.VolumeRebalanceStatus, error) {
+
+	if opts == nil {
+		opts = &VolumeRebalanceOptions{}
+	}
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/volumes/"+id+"/rebalance", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, c.volumeNotSupported(id)
+	}
+	switch r.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusConflict:
+		return nil, ErrRebalanceInProgress
+	case http.StatusNoContent:
+		return nil, ErrNothingToRebalance
+	default:
+		return nil, responseError(r)
+	}
+
+	// Wait for response, reporting progress while pending
+	location, err := r.Location()
+	if err != nil {
+		return nil, err
+	}
+	r, err = c.pollOperationUntil(location.String(), interval, time.Time{},
+		func(r *http.Response) {
+			if opts.Progress == nil || r.ContentLength == 0 {
+				return
+			}
+			var status api.VolumeRebalanceStatus
+			if utils.GetJsonFromResponse(r, &status) == nil {
+				opts.Progress(&status)
+			}
+		})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var status api.VolumeRebalanceStatus
+	err = utils.GetJsonFromResponse(r, &status)
+	if err != nil {
+		return nil, err
+	}
+	if status.State == api.RebalanceFailed {
+		return &status, fmt.Errorf("Rebalance of volume %v failed, %v files "+
+			"could not be moved", id, status.FilesFailed)
+	}
+
+	return &status, nil
+}
+
+// VolumeBrickStatus returns whether each brick process of the volume is
+// online
+func (c *Client) VolumeBrickStatus(id string) (*api.VolumeBrickStatusResponse, error) {
+	status, _, err := c.volumeBrickStatus(id)
+	return status, err
+}
+
+// Like VolumeBrickStatus, but also returns the status code of a failed
+// response
+func (c *Client) volumeBrickStatus(id string) (
+	*api.VolumeBrickStatusResponse, int, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/"+id+"/bricks", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, r.StatusCode, responseError(r)
+	}
+
+	// Read JSON response
+	var status api.VolumeBrickStatusResponse
+	err = utils.GetJsonFromResponse(r, &status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &status, 0, nil
+}
+
+// Utilization of a brick of a volume
+type BrickUtil struct {
+	api.BrickUtilization
+
+	NodeId string
+
+	// Storage hostname of the node and mount point of the brick
+	Host string
+	Path string
+}
+
+// BrickUtilization returns the space and inodes used on each brick of
+// the volume. Bricks the server could not get the usage of, such as
+// bricks which are offline, are returned with Online unset and no
+// usage instead of failing the call.
+func (c *Client) BrickUtilization(volumeId string) ([]BrickUtil, error) {
+	volume, err := c.VolumeInfo(volumeId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create request
+	req, err := http.NewRequest("GET",
+		c.host+"/volumes/"+volumeId+"/bricks/utilization", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		// The volume exists, so the server does not report usage
+		return nil, ErrNotSupported
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var usage api.VolumeBrickUtilizationResponse
+	err = utils.GetJsonFromResponse(r, &usage)
+	if err != nil {
+		return nil, err
+	}
+	reported := make(map[string]api.BrickUtilization, len(usage.Bricks))
+	for _, brick := range usage.Bricks {
+		reported[brick.Id] = brick
+	}
+
+	// Get the storage hostname of every node holding a brick
+	nodes := []string{}
+	seen := map[string]bool{}
+	for _, brick := range volume.Bricks {
+		if !seen[brick.NodeId] {
+			seen[brick.NodeId] = true
+			nodes = append(nodes, brick.NodeId)
+		}
+	}
+	storage := make([]string, len(nodes))
+	err = forEachConcurrentErr(len(nodes), DEFAULT_BULK_CONCURRENCY,
+		func(i int) error {
+			node, err := c.NodeInfo(nodes[i])
+			if err != nil {
+				return err
+			}
+			if len(node.Hostnames.Storage) != 0 {
+				storage[i] = node.Hostnames.Storage[0]
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	hosts := make(map[string]string, len(nodes))
+	for i, node := range nodes {
+		hosts[node] = storage[i]
+	}
+
+	bricks := make([]BrickUtil, len(volume.Bricks))
+	for i, brick := range volume.Bricks {
+		util, ok := reported[brick.Id]
+		if !ok || !util.Online {
+			util = api.BrickUtilization{Id: brick.Id}
+		}
+		bricks[i] = BrickUtil{
+			BrickUtilization: util,
+			NodeId:           brick.NodeId,
+			Host:             hosts[brick.NodeId],
+			Path:             brick.Path,
+		}
+	}
+
+	return bricks, nil
+}
+
+// Returned by VolumeExpandAndWait when the volume was expanded but some
+// of the new bricks did not come online in time
+type BricksOfflineError struct {
+	VolumeId string
+	Bricks   []string
+}
+
+func (e *BricksOfflineError) Error() string {
+	return fmt.Sprintf("Volume %v was expanded but bricks %v did not come online",
+		e.VolumeId, strings.Join(e.Bricks, ", "))
+}
+
+// Options for VolumeExpandAndWait
+type VolumeExpandWaitOptions struct {
+	// Time between brick status polls, one second if not set
+	PollInterval time.Duration
+
+	// Time to wait for the new bricks to come online, two minutes if
+	// not set
+	Timeout time.Duration
+}
+
+// VolumeExpandAndWait expands the volume by addSize GB and then waits
+// for the bricks added by the expansion to come online before returning
+// the expanded volume. If some of them do not come online in time the
+// expanded volume is returned along with a BricksOfflineError listing
+// them. The server must report brick status with GET
+// /volumes/{id}/bricks; if it cannot, ErrNotSupported is returned and
+// the volume is not expanded.
+func (c *Client) VolumeExpandAndWait(id string, addSize int,
+	opts *VolumeExpandWaitOptions) (*api.VolumeInfoResponse, error) {
+
+	if opts == nil {
+		opts = &VolumeExpandWaitOptions{}
+	}
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = time.Second
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+
+	// The new bricks cannot be checked on servers which do not report
+	// brick status, so do not expand the volume on them
+	_, code, err := c.volumeBrickStatus(id)
+	if code == http.StatusNotFound || code == http.StatusMethodNotAllowed {
+		return nil, c.volumeNotSupported(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the bricks the volume had before the expansion
+	volume, err := c.VolumeInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, brick := range volume.Bricks {
+		existing[brick.Id] = true
+	}
+
+	volume, err = c.VolumeExpand(id, &api.VolumeExpandRequest{Size: addSize})
+	if err != nil {
+		return nil, err
+	}
+	added := make(map[string]bool)
+	for _, brick := range volume.Bricks {
+		if !existing[brick.Id] {
+			added[brick.Id] = true
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		status, _, err := c.volumeBrickStatus(id)
+		if err != nil {
+			return volume, err
+		}
+
+		online := make(map[string]bool)
+		for _, brick := range status.Bricks {
+			online[brick.Id] = brick.Online
+		}
+		offline := []string{}
+		for brickId := range added {
+			if !online[brickId] {
+				offline = append(offline, brickId)
+			}
+		}
+		if len(offline) == 0 {
+			return volume, nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			sort.Strings(offline)
+			return volume, &BricksOfflineError{
+				VolumeId: id,
+				Bricks:   offline,
+			}
+		}
+		err = c.sleep(interval)
+		if err != nil {
+			return volume, err
+		}
+	}
+}
+
+// Returned by VolumeDeleteAndVerify when the volume was deleted but some
+// of its bricks are still present on their devices and must be cleaned
+// up manually
+type OrphanedBricksError struct {
+	VolumeId string
+	Bricks   []api.BrickInfo
+}
+
+func (e *OrphanedBricksError) Error() string {
+	bricks := make([]string, 0, len(e.Bricks))
+	for _, brick := range e.Bricks {
+		bricks = append(bricks, fmt.Sprintf("%v on node %v (%v)",
+			brick.Id, brick.NodeId, brick.Path))
+	}
+	return fmt.Sprintf("Volume %v was deleted but %v bricks remain: %v",
+		e.VolumeId, len(bricks), strings.Join(bricks, ", "))
+}
+
+// Options for VolumeDeleteAndVerify
+type VolumeDeleteVerifyOptions struct {
+	// Maximum number of devices checked at the same time,
+	// DEFAULT_BULK_CONCURRENCY if not set
+	Concurrency int
+}
+
+// VolumeDeleteAndVerify deletes the volume and then asks the server to
+// scan the devices which held its bricks, so that bricks which were
+// left behind on a node, for example because it was down during the
+// delete, are found. If any are, an OrphanedBricksError listing them is
+// returned.
+//
+// The server scans the logical volumes of a device on its node with
+// GET /devices/{id}/scan, answering with an api.DeviceBrickScanResponse.
+// The heketi database cannot be used instead, because it drops the
+// bricks of a deleted volume even when they remain on the node. If the
+// server cannot scan devices, ErrNotSupported is returned and the
+// volume is not deleted.
+func (c *Client) VolumeDeleteAndVerify(id string,
+	opts *VolumeDeleteVerifyOptions) error {
+
+	if opts == nil {
+		opts = &VolumeDeleteVerifyOptions{}
+	}
+
+	// Remember where the bricks of the volume are
+	volume, err := c.VolumeInfo(id)
+	if err != nil {
+		return err
+	}
+	bricks := make(map[string]api.BrickInfo)
+	devices := []string{}
+	seen := make(map[string]bool)
+	for _, brick := range volume.Bricks {
+		bricks[brick.Id] = brick
+		if !seen[brick.DeviceId] {
+			seen[brick.DeviceId] = true
+			devices = append(devices, brick.DeviceId)
+		}
+	}
+
+	// Make sure the bricks can be verified before deleting anything
+	if len(devices) != 0 {
+		_, err = c.deviceBrickScan(devices[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.VolumeDelete(id)
+	if err != nil {
+		return err
+	}
+
+	// Look for bricks of the volume left on its devices
+	var lock sync.Mutex
+	orphans := make(map[string]api.BrickInfo)
+	err = forEachConcurrentErr(len(devices), opts.Concurrency,
+		func(i int) error {
+			found, err := c.deviceBrickScan(devices[i])
+			if err != nil {
+				return err
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			for _, brickId := range found {
+				if brick, ok := bricks[brickId]; ok {
+					orphans[brickId] = brick
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("Volume %v was deleted but its bricks could not "+
+			"be verified: %v", id, err)
+	}
+
+	if len(orphans) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(orphans))
+	for brickId := range orphans {
+		ids = append(ids, brickId)
+	}
+	sort.Strings(ids)
+	orphanErr := &OrphanedBricksError{VolumeId: id}
+	for _, brickId := range ids {
+		orphanErr.Bricks = append(orphanErr.Bricks, orphans[brickId])
+	}
+	return orphanErr
+}
+
+// Return the ids of the bricks the server finds on the node of the
+// device
+func (c *Client) deviceBrickScan(id string) ([]string, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/devices/"+id+"/scan", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		// A missing device is also answered with 404
+		_, err := c.DeviceInfo(id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrNotSupported
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var scan api.DeviceBrickScanResponse
+	err = utils.GetJsonFromResponse(r, &scan)
+	if err != nil {
+		return nil, err
+	}
+
+	return scan.Bricks, nil
+}
+
+var (
+	ErrVolumeNameTaken = errors.New("The volume name is already in use")
+)
+
+// Returned by VolumeSetName and VolumeSetTags if the server rejected
+// the change as invalid
+type InvalidVolumeUpdateError struct {
+	Reason string
+}
+
+func (e *InvalidVolumeUpdateError) Error() string {
+	return "Invalid volume update: " + e.Reason
+}
+
+// VolumeSetName renames the volume and returns the volume as read back
+// from the server. It returns ErrVolumeNameTaken if another volume
+// already has the name. Heketi servers cannot rename the gluster volume
+// and refuse any other name with an InvalidVolumeUpdateError.
+func (c *Client) VolumeSetName(id, name string) (*api.VolumeInfoResponse, error) {
+	if name == "" {
+		return nil, &InvalidVolumeUpdateError{Reason: "missing volume name"}
+	}
+	return c.volumeUpdate(id, &api.VolumeUpdateRequest{Name: name})
+}
+
+// VolumeSetTags replaces the tags of the volume and returns the volume
+// as read back from the server
+func (c *Client) VolumeSetTags(id string, tags map[string]string) (
+	*api.VolumeInfoResponse, error) {
+
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return c.volumeUpdate(id, &api.VolumeUpdateRequest{Tags: tags})
+}
+
+func (c *Client) volumeUpdate(id string, request *api.VolumeUpdateRequest) (
+	*api.VolumeInfoResponse, error) {
+
+	err := request.Validate()
+	if err != nil {
+		return nil, &InvalidVolumeUpdateError{Reason: err.Error()}
+	}
+
+	// Marshal request to JSON
+	buffer, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a request
+	req, err := http.NewRequest("POST",
+		c.host+"/volumes/"+id+"/update",
+		bytes.NewBuffer(buffer))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send request
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, c.volumeNotSupported(id)
+	}
+
+	// The server may apply the change right away or in the background
+	if r.StatusCode == http.StatusAccepted {
+		r, err = c.waitForResponseWithTimer(r, time.Second)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Body.Close()
+	}
+	switch r.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+	case http.StatusConflict:
+		return nil, ErrVolumeNameTaken
+	case http.StatusBadRequest:
+		return nil, &InvalidVolumeUpdateError{Reason: responseError(r).Error()}
+	default:
+		return nil, responseError(r)
+	}
+
+	// Read back the volume to confirm the change
+	volume, err := c.VolumeInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.Name != "" && volume.Name != request.Name {
+		return volume, fmt.Errorf("Volume %v was not renamed to %v", id, request.Name)
+	}
+	if request.Tags != nil && !reflect.DeepEqual(volume.Tags, request.Tags) &&
+		!(len(volume.Tags) == 0 && len(request.Tags) == 0) {
+		return volume, fmt.Errorf("Tags of volume %v were not updated", id)
+	}
+
+	return volume, nil
+}
+
+// Result of VolumeSplitBrainInfo
+type SplitBrainReport struct {
+	VolumeId string
+
+	// Files in split-brain on any brick, sorted and without duplicates
+	Files []string
+
+	// Bricks reporting files in split-brain
+	AffectedBricks []string
+
+	// Number of entries waiting to be healed on each brick, by brick id
+	PendingHeals map[string]uint64
+}
+
+// Returns true if any file of the volume is in split-brain
+func (r *SplitBrainReport) InSplitBrain() bool {
+	return len(r.Files) != 0
+}
+
+// VolumeSplitBrainInfo reports the files of the volume which are in
+// split-brain, the bricks reporting them, and the number of entries
+// each brick has waiting to be healed. It returns ErrNotSupported if
+// the server does not provide heal information.
+func (c *Client) VolumeSplitBrainInfo(id string) (*SplitBrainReport, error) {
+
+	// Create request
+	req, err := http.NewRequest("GET", c.host+"/volumes/"+id+"/heal", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set token
+	err = c.setToken(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get info
+	r, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if isNotSupported(r) {
+		return nil, c.volumeNotSupported(id)
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, responseError(r)
+	}
+
+	// Read JSON response
+	var heal api.VolumeHealInfoResponse
+	err = utils.GetJsonFromResponse(r, &heal)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SplitBrainReport{
+		VolumeId:     id,
+		Files:        []string{},
+		PendingHeals: make(map[string]uint64, len(heal.Bricks)),
+	}
+	files := map[string]bool{}
+	for _, brick := range heal.Bricks {
+		report.PendingHeals[brick.Id] = brick.PendingHeals
+		if len(brick.SplitBrainFiles) == 0 {
+			continue
+		}
+		report.AffectedBricks = append(report.AffectedBricks, brick.Id)
+		for _, file := range brick.SplitBrainFiles {
+			if !files[file] {
+				files[file] = true
+				report.Files = append(report.Files, file)
+			}
+		}
+	}
+	sort.Strings(report.Files)
+
+	return report, nil
+}
+
+// Returns the number of entries waiting to be healed on all bricks
+func (r *SplitBrainReport) Pending() uint64 {
+	var pending uint64
+	for _, n := range r.PendingHeals {
+		pending += n
+	}
+	return pending
+}
+
+// Returned by VolumeWaitHeal when files are in split-brain, which
+// self-heal cannot resolve
+var ErrVolumeInSplitBrain = errors.New("Files of the volume are in split-brain")
+
+// Options for VolumeWaitHeal
+type VolumeWaitHealOptions struct {
+	// Time between heal info polls, ten seconds if not set
+	PollInterval time.Duration
+
+	// If set, called with the heal state of every poll, including the
+	// last one
+	Progress func(report *SplitBrainReport)
+}
+
+// VolumeWaitHeal waits until no brick of the volume has entries
+// waiting to be healed, and returns the last heal state. It returns
+// ErrWaitTimeout if self-heal has not finished within timeout, and
+// ErrVolumeInSplitBrain as soon as any file is in split-brain, in both
+// cases together with the last heal state. A zero timeout waits
+// forever.
+func (c *Client) VolumeWaitHeal(id string, timeout time.Duration,
+	opts *VolumeWaitHealOptions) (*SplitBrainReport, error) {
+
+	if opts == nil {
+		opts = &VolumeWaitHealOptions{}
+	}
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		report, err := c.VolumeSplitBrainInfo(id)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(report)
+		}
+		if report.InSplitBrain() {
+			return report, ErrVolumeInSplitBrain
+		}
+		if report.Pending() == 0 {
+			return report, nil
+		}
+		if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return report, ErrWaitTimeout
+		}
+		err = c.sleep(interval)
+		if err != nil {
+			return report, err
+		}
+	}
+}
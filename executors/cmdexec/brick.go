@@ -11,6 +11,7 @@ package cmdexec
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/heketi/heketi/executors"
@@ -30,6 +31,12 @@ func (s *CmdExecutor) BrickCreate(host string,
 	godbc.Require(brick.Path != "")
 	godbc.Require(s.Fstab != "")
 
+	err := s.AccessBrickOps(host)
+	if err != nil {
+		return nil, err
+	}
+	defer s.FreeBrickOps(host)
+
 	// make local vars with more accurate names to cut down on name confusion
 	// and make future refactoring easier
 	brickPath := brick.Path
@@ -91,20 +98,65 @@ func (s *CmdExecutor) BrickCreate(host string,
 	}
 
 	// Execute commands
-	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	_, err = s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
 	if err != nil {
 		// Cleanup
-		s.BrickDestroy(host, brick)
+		s.brickDestroy(host, brick)
 		return nil, err
 	}
 
+	// The size is only reported, so fall back to the requested size
+	// rather than failing the created brick
+	size, err := s.brickLvSize(host, brick)
+	if err != nil {
+		logger.Warning("Unable to determine size of brick %v on host %v, "+
+			"using requested size %vK: %v", brick.Name, host, brick.Size, err)
+		size = brick.Size
+	}
+
 	// Save brick location
 	b := &executors.BrickInfo{
-		Path: brickPath,
+		Path:      brickPath,
+		LvPath:    devnode,
+		ThinPool:  utils.BrickThinLvName(brick.VgId, brick.Name),
+		MountPath: mountPath,
+		Size:      size,
 	}
 	return b, nil
 }
 
+// Query the size actually allocated to the brick LV, which LVM may have
+// rounded up to a multiple of the extent size
+func (s *CmdExecutor) brickLvSize(host string,
+	brick *executors.BrickRequest) (uint64, error) {
+
+	commands := []string{
+		fmt.Sprintf("lvs --noheadings --units b --nosuffix -o lv_size %v/%v",
+			utils.VgIdToName(brick.VgId),
+			utils.BrickIdToName(brick.Name)),
+	}
+	output, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	if err != nil {
+		return 0, err
+	}
+	if len(output) != 1 {
+		return 0, fmt.Errorf("Unexpected output of lvs: %v", output)
+	}
+	return parseLvSize(output[0])
+}
+
+// Parse the output of lvs reporting the size of a single LV in bytes,
+// and return it in KB. LVM marks sizes it had to round for display
+// with a leading "<", which is ignored.
+func parseLvSize(output string) (uint64, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(output), "<")
+	size, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse LV size %q: %v", output, err)
+	}
+	return (size + 1023) / 1024, nil
+}
+
 func (s *CmdExecutor) BrickDestroy(host string,
 	brick *executors.BrickRequest) error {
 
@@ -113,6 +165,19 @@ func (s *CmdExecutor) BrickDestroy(host string,
 	godbc.Require(brick.Name != "")
 	godbc.Require(brick.VgId != "")
 
+	err := s.AccessBrickOps(host)
+	if err != nil {
+		return err
+	}
+	defer s.FreeBrickOps(host)
+
+	return s.brickDestroy(host, brick)
+}
+
+// Remove the brick, the caller must hold a brick operation slot on host
+func (s *CmdExecutor) brickDestroy(host string,
+	brick *executors.BrickRequest) error {
+
 	mp := utils.BrickMountPoint(brick.VgId, brick.Name)
 	// Try to unmount first
 	commands := []string{
@@ -10,8 +10,11 @@
 package cmdexec
 
 import (
+	"errors"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/heketi/heketi/executors"
 	"github.com/heketi/heketi/pkg/utils"
@@ -42,6 +45,15 @@ func TestSshExecBrickCreate(t *testing.T) {
 		useSudo bool) ([]string, error) {
 
 		tests.Assert(t, host == "myhost:100", host)
+
+		// Size query after the brick is created
+		if len(commands) == 1 && strings.HasPrefix(commands[0], "lvs ") {
+			tests.Assert(t,
+				commands[0] == "lvs --noheadings --units b --nosuffix "+
+					"-o lv_size vg_xvgid/brick_id", commands[0])
+			return []string{"  12288\n"}, nil
+		}
+
 		tests.Assert(t, len(commands) == 6)
 
 		for i, cmd := range commands {
@@ -85,8 +97,9 @@ func TestSshExecBrickCreate(t *testing.T) {
 	}
 
 	// Create Brick
-	_, err = s.BrickCreate("myhost", b)
+	info, err := s.BrickCreate("myhost", b)
 	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Size == 12, info.Size)
 
 }
 
@@ -115,6 +128,15 @@ func TestSshExecBrickCreateWithGid(t *testing.T) {
 		useSudo bool) ([]string, error) {
 
 		tests.Assert(t, host == "myhost:100", host)
+
+		// Size query after the brick is created
+		if len(commands) == 1 && strings.HasPrefix(commands[0], "lvs ") {
+			tests.Assert(t,
+				commands[0] == "lvs --noheadings --units b --nosuffix "+
+					"-o lv_size vg_xvgid/brick_id", commands[0])
+			return []string{"  12288\n"}, nil
+		}
+
 		tests.Assert(t, len(commands) == 8)
 
 		for i, cmd := range commands {
@@ -168,8 +190,9 @@ func TestSshExecBrickCreateWithGid(t *testing.T) {
 	}
 
 	// Create Brick
-	_, err = s.BrickCreate("myhost", b)
+	info, err := s.BrickCreate("myhost", b)
 	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Size == 12, info.Size)
 
 }
 
@@ -198,6 +221,15 @@ func TestSshExecBrickCreateSudo(t *testing.T) {
 		useSudo bool) ([]string, error) {
 
 		tests.Assert(t, host == "myhost:100", host)
+
+		// Size query after the brick is created
+		if len(commands) == 1 && strings.HasPrefix(commands[0], "lvs ") {
+			tests.Assert(t,
+				commands[0] == "lvs --noheadings --units b --nosuffix "+
+					"-o lv_size vg_xvgid/brick_id", commands[0])
+			return []string{"  12288\n"}, nil
+		}
+
 		tests.Assert(t, len(commands) == 6)
 		tests.Assert(t, useSudo == true)
 
@@ -242,9 +274,73 @@ func TestSshExecBrickCreateSudo(t *testing.T) {
 	}
 
 	// Create Brick
-	_, err = s.BrickCreate("myhost", b)
+	info, err := s.BrickCreate("myhost", b)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Size == 12, info.Size)
+
+}
+
+func TestSshExecBrickCreateInfo(t *testing.T) {
+	f := NewCommandFaker()
+	s, err := NewFakeExecutor(f)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	b := &executors.BrickRequest{
+		VgId:             "xvgid",
+		Name:             "id",
+		TpSize:           100,
+		Size:             10,
+		PoolMetadataSize: 5,
+		Path:             utils.BrickPath("xvgid", "id"),
+	}
+
+	// LVM rounded the brick up to a full 4M extent. The size is queried
+	// on its own, after the commands which create the brick.
+	lvsOutput := "  4194304\n"
+	var lvsErr error
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		if len(commands) == 1 && strings.HasPrefix(commands[0], "lvs ") {
+			return []string{lvsOutput}, lvsErr
+		}
+		for _, cmd := range commands {
+			tests.Assert(t, !strings.HasPrefix(cmd, "lvs "), commands)
+		}
+		return make([]string, len(commands)), nil
+	}
+
+	info, err := s.BrickCreate("myhost", b)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Path == "/var/lib/heketi/mounts/vg_xvgid/brick_id/brick",
+		info.Path)
+	tests.Assert(t, info.LvPath == "/dev/mapper/vg_xvgid-brick_id", info.LvPath)
+	tests.Assert(t, info.ThinPool == "vg_xvgid/tp_id", info.ThinPool)
+	tests.Assert(t, info.MountPath == "/var/lib/heketi/mounts/vg_xvgid/brick_id",
+		info.MountPath)
+	tests.Assert(t, info.Size == 4096, info.Size)
+
+	// Sizes LVM rounded for display are marked with a "<"
+	lvsOutput = "  <8388608\n"
+	info, err = s.BrickCreate("myhost", b)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Size == 8192, info.Size)
+
+	// Unparsable output falls back to the requested size
+	lvsOutput = "garbage"
+	info, err = s.BrickCreate("myhost", b)
 	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Size == 10, info.Size)
 
+	// So does a failed query, which does not fail the created brick
+	lvsOutput = ""
+	lvsErr = errors.New("lvs failed")
+	info, err = s.BrickCreate("myhost", b)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, info.Size == 10, info.Size)
 }
 
 func TestSshExecBrickDestroy(t *testing.T) {
@@ -303,3 +399,102 @@ func TestSshExecBrickDestroy(t *testing.T) {
 	err = s.BrickDestroy("myhost", b)
 	tests.Assert(t, err == nil, err)
 }
+
+func TestSshExecBrickDestroyPerNodeLimit(t *testing.T) {
+	f := NewCommandFaker()
+	s, err := NewFakeExecutor(f)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+	s.BrickOpsPerNode = 1
+
+	// Record the order in which the commands reach the node
+	var lock sync.Mutex
+	executed := []string{}
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		lock.Lock()
+		defer lock.Unlock()
+		executed = append(executed, commands...)
+		return nil, nil
+	}
+
+	// Destroy two bricks on the same node at the same time
+	names := []string{"id1", "id2"}
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		go func(name string) {
+			b := &executors.BrickRequest{
+				VgId: "xvgid",
+				Name: name,
+			}
+			errs <- s.BrickDestroy("myhost", b)
+		}(name)
+	}
+	for range names {
+		err := <-errs
+		tests.Assert(t, err == nil, err)
+	}
+
+	// The commands of each brick must not be interleaved
+	tests.Assert(t, len(executed) == 8, executed)
+	first := "brick_id1"
+	if !strings.Contains(executed[0], first) {
+		first = "brick_id2"
+	}
+	for i, cmd := range executed {
+		if i < 4 {
+			tests.Assert(t, strings.Contains(cmd, first) ||
+				strings.Contains(cmd, strings.Replace(first, "brick_", "tp_", 1)), executed)
+		} else {
+			tests.Assert(t, !strings.Contains(cmd, first), executed)
+		}
+	}
+}
+
+func TestSshExecBrickCreatePerNodeTimeout(t *testing.T) {
+	f := NewCommandFaker()
+	s, err := NewFakeExecutor(f)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+	s.BrickOpsPerNode = 1
+	s.BrickOpsTimeout = 10 * time.Millisecond
+
+	// Hold the only slot on the node
+	err = s.AccessBrickOps("myhost")
+	tests.Assert(t, err == nil, err)
+
+	b := &executors.BrickRequest{
+		VgId:             "xvgid",
+		Name:             "id",
+		TpSize:           100,
+		Size:             10,
+		PoolMetadataSize: 5,
+		Path:             utils.BrickPath("xvgid", "id"),
+	}
+	called := false
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		called = true
+		return nil, nil
+	}
+	_, err = s.BrickCreate("myhost", b)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, strings.Contains(err.Error(), "Timed out"), err)
+	tests.Assert(t, called == false)
+
+	// Other nodes are not affected
+	_, err = s.BrickCreate("otherhost", b)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, called == true)
+
+	// Once the slot is freed the node takes new work
+	s.FreeBrickOps("myhost")
+	_, err = s.BrickCreate("myhost", b)
+	tests.Assert(t, err == nil, err)
+}
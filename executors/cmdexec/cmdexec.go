@@ -10,7 +10,9 @@
 package cmdexec
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/heketi/heketi/pkg/utils"
 )
@@ -31,6 +33,16 @@ type CmdExecutor struct {
 
 	RemoteExecutor RemoteCommandTransport
 	Fstab          string
+
+	// Maximum number of brick create/destroy operations run on a
+	// single host at a time, 0 means no limit
+	BrickOpsPerNode int
+	// Maximum time to wait for a brick operation slot on a host,
+	// 0 means wait until one is available
+	BrickOpsTimeout time.Duration
+	brickOpsmap     map[string]chan bool
+	// Command run by GlusterdCheck, the service status if not set
+	GlusterdCheckCommand string
 }
 
 func (s *CmdExecutor) AccessConnection(host string) {
@@ -57,6 +69,49 @@ func (s *CmdExecutor) FreeConnection(host string) {
 	<-c
 }
 
+// Wait until the host can take another brick operation
+func (s *CmdExecutor) AccessBrickOps(host string) error {
+	if s.BrickOpsPerNode <= 0 {
+		return nil
+	}
+
+	s.Lock.Lock()
+	if s.brickOpsmap == nil {
+		s.brickOpsmap = make(map[string]chan bool)
+	}
+	c, ok := s.brickOpsmap[host]
+	if !ok {
+		c = make(chan bool, s.BrickOpsPerNode)
+		s.brickOpsmap[host] = c
+	}
+	s.Lock.Unlock()
+
+	if s.BrickOpsTimeout <= 0 {
+		c <- true
+		return nil
+	}
+
+	select {
+	case c <- true:
+		return nil
+	case <-time.After(s.BrickOpsTimeout):
+		return fmt.Errorf("Timed out after %v waiting for other brick "+
+			"operations on host %v to complete", s.BrickOpsTimeout, host)
+	}
+}
+
+func (s *CmdExecutor) FreeBrickOps(host string) {
+	if s.BrickOpsPerNode <= 0 {
+		return
+	}
+
+	s.Lock.Lock()
+	c := s.brickOpsmap[host]
+	s.Lock.Unlock()
+
+	<-c
+}
+
 func (s *CmdExecutor) SetLogLevel(level string) {
 	switch level {
 	case "none":
@@ -9,9 +9,52 @@
 
 package cmdexec
 
+import (
+	"fmt"
+)
+
+// Checks GlusterdCheck can run on a node
+const (
+	// Ask the service manager whether glusterd is running (default)
+	GlusterdCheckStatus = "status"
+	// Ask glusterd for its pool, which also fails if it is running
+	// but not answering requests
+	GlusterdCheckPool = "pool"
+	// Run the command set in glusterd_check_command
+	GlusterdCheckCustom = "custom"
+)
+
 type CmdConfig struct {
 	Fstab                string `json:"fstab"`
 	Sudo                 bool   `json:"sudo"`
 	SnapShotLimit        int    `json:"snapshot_limit"`
 	RebalanceOnExpansion bool   `json:"rebalance_on_expansion"`
+
+	// Limit the number of concurrent brick create/destroy operations
+	// per node to avoid LVM lock contention. The timeout is in seconds.
+	BrickOpsPerNode int `json:"brick_ops_per_node"`
+	BrickOpsTimeout int `json:"brick_ops_timeout"`
+
+	// Check used to decide whether glusterd is up on a node, see
+	// GlusterdCheckStatus, GlusterdCheckPool and GlusterdCheckCustom
+	GlusterdCheck        string `json:"glusterd_check"`
+	GlusterdCheckCommand string `json:"glusterd_check_command"`
+}
+
+// Return the command run on a node to check glusterd, as selected by
+// the configuration
+func (c *CmdConfig) GlusterdCheckCmd() (string, error) {
+	switch c.GlusterdCheck {
+	case "", GlusterdCheckStatus:
+		return glusterdStatusCommand, nil
+	case GlusterdCheckPool:
+		return "gluster --mode=script pool list", nil
+	case GlusterdCheckCustom:
+		if c.GlusterdCheckCommand == "" {
+			return "", fmt.Errorf("Missing glusterd_check_command for custom glusterd check")
+		}
+		return c.GlusterdCheckCommand, nil
+	default:
+		return "", fmt.Errorf("Unknown glusterd check: %v", c.GlusterdCheck)
+	}
 }
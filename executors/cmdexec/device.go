@@ -102,6 +102,35 @@ func (s *CmdExecutor) DeviceTeardown(host, device, vgid string) error {
 	return nil
 }
 
+// Return the ids of the bricks which have a logical volume in the volume
+// group of the device, whether heketi still knows about them or not
+func (s *CmdExecutor) DeviceBricks(host, vgid string) ([]string, error) {
+
+	// Setup command
+	commands := []string{
+		fmt.Sprintf("lvs --noheadings --options lv_name %v", utils.VgIdToName(vgid)),
+	}
+
+	// Execute command
+	b, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	// Example:
+	//   brick_a17c621ade79017b48cc0042bea86510
+	//   tp_a17c621ade79017b48cc0042bea86510
+	bricks := []string{}
+	prefix := utils.BrickIdToName("")
+	for _, line := range strings.Split(b[0], "\n") {
+		name := strings.TrimSpace(line)
+		if strings.HasPrefix(name, prefix) {
+			bricks = append(bricks, strings.TrimPrefix(name, prefix))
+		}
+	}
+	return bricks, nil
+}
+
 func (s *CmdExecutor) getVgSizeFromNode(
 	d *executors.DeviceInfo,
 	host, device, vgid string) error {
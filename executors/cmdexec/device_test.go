@@ -0,0 +1,53 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package cmdexec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+func TestSshExecDeviceBricks(t *testing.T) {
+	f := NewCommandFaker()
+	s, err := NewFakeExecutor(f)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:22", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t,
+			commands[0] == "lvs --noheadings --options lv_name vg_xvgid",
+			commands)
+		return []string{"  brick_b1\n  tp_b1\n  brick_b2\n  tp_b2\n"}, nil
+	}
+
+	bricks, err := s.DeviceBricks("myhost", "xvgid")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, reflect.DeepEqual(bricks, []string{"b1", "b2"}), bricks)
+
+	// Empty volume group
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		return []string{""}, nil
+	}
+	bricks, err = s.DeviceBricks("myhost", "xvgid")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, len(bricks) == 0, bricks)
+}
@@ -11,10 +11,21 @@ package cmdexec
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/lpabon/godbc"
 )
 
+const (
+	// Maximum number of nodes probed at the same time by ClusterFormation
+	clusterFormationConcurrency = 4
+
+	// Default command run by GlusterdCheck
+	glusterdStatusCommand = "systemctl status glusterd"
+)
+
 // :TODO: Rename this function to NodeInit or something
 func (s *CmdExecutor) PeerProbe(host, newnode string) error {
 
@@ -48,6 +59,15 @@ func (s *CmdExecutor) PeerProbe(host, newnode string) error {
 }
 
 func (s *CmdExecutor) PeerDetach(host, detachnode string) error {
+	err := s.peerDetach(host, detachnode)
+	if err != nil {
+		logger.Err(err)
+	}
+
+	return nil
+}
+
+func (s *CmdExecutor) peerDetach(host, detachnode string) error {
 	godbc.Require(host != "")
 	godbc.Require(detachnode != "")
 
@@ -57,19 +77,131 @@ func (s *CmdExecutor) PeerDetach(host, detachnode string) error {
 		fmt.Sprintf("gluster peer detach %v", detachnode),
 	}
 	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
+	return err
+}
+
+// Return the hostnames of the nodes in the trusted pool of host, as
+// listed by "gluster pool list":
+//
+//	UUID					Hostname 	State
+//	8a2c6b1e-...	node2    	Connected
+//	0f3d5e7a-...	localhost	Connected
+func (s *CmdExecutor) poolList(host string) (map[string]bool, error) {
+	commands := []string{
+		"gluster --mode=script pool list",
+	}
+	output, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
 	if err != nil {
-		logger.Err(err)
+		return nil, err
 	}
 
-	return nil
+	peers := map[string]bool{}
+	for i, line := range strings.Split(output[0], "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 2 {
+			// Header or empty line
+			continue
+		}
+		peers[fields[1]] = true
+	}
+	return peers, nil
+}
+
+// Form a cluster by probing each of the new nodes from the seed host.
+// Nodes which are already peers of the seed are accepted by gluster, so
+// calling this again with the same nodes is safe. If any node fails to
+// be probed a ClusterFormationError is returned, and if rollback is set
+// the nodes which were added to the pool by this call are detached
+// again. Nodes which were already peers of the seed are left alone.
+func (s *CmdExecutor) ClusterFormation(seedHost string,
+	newNodes []string, rollback bool) error {
+
+	godbc.Require(seedHost != "")
+
+	// Rolling back must not detach nodes which were peers before
+	var existing map[string]bool
+	if rollback {
+		var err error
+		existing, err = s.poolList(seedHost)
+		if err != nil {
+			return logger.Err(fmt.Errorf(
+				"Unable to list the peers of %v: %v", seedHost, err))
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		probed []string
+	)
+	failed := make(map[string]error)
+	sema := make(chan bool, clusterFormationConcurrency)
+	seen := map[string]bool{seedHost: true}
+	for _, node := range newNodes {
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+
+		wg.Add(1)
+		sema <- true
+		go func(node string) {
+			defer func() {
+				<-sema
+				wg.Done()
+			}()
+
+			err := s.PeerProbe(seedHost, node)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failed[node] = err
+			} else {
+				probed = append(probed, node)
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	cerr := &executors.ClusterFormationError{
+		Failed: failed,
+	}
+	if rollback {
+		detachFailed := make(map[string]error)
+		for _, node := range probed {
+			if existing[node] {
+				continue
+			}
+			err := s.peerDetach(seedHost, node)
+			if err != nil {
+				logger.Err(err)
+				detachFailed[node] = err
+			}
+		}
+		if len(detachFailed) == 0 {
+			cerr.RolledBack = true
+		} else {
+			cerr.DetachFailed = detachFailed
+		}
+	}
+	return cerr
 }
 
 func (s *CmdExecutor) GlusterdCheck(host string) error {
 	godbc.Require(host != "")
 
 	logger.Info("Check Glusterd service status in node %v", host)
+	command := s.GlusterdCheckCommand
+	if command == "" {
+		command = glusterdStatusCommand
+	}
 	commands := []string{
-		fmt.Sprintf("systemctl status glusterd"),
+		command,
 	}
 	_, err := s.RemoteExecutor.RemoteCommandExecute(host, commands, 10)
 	if err != nil {
@@ -10,8 +10,14 @@
 package cmdexec
 
 import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/heketi/heketi/executors"
 	"github.com/heketi/tests"
 )
 
@@ -99,4 +105,127 @@ func TestSshExecGlusterdCheck(t *testing.T) {
 	// Call function
 	err = s.GlusterdCheck("newhost")
 	tests.Assert(t, err == nil, err)
+	// A configured check replaces the service status
+	s.GlusterdCheckCommand = "gluster --mode=script pool list"
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t, commands[0] == "gluster --mode=script pool list", commands)
+
+		return nil, nil
+	}
+	err = s.GlusterdCheck("newhost")
+	tests.Assert(t, err == nil, err)
+}
+
+func TestSshExecClusterFormation(t *testing.T) {
+	f := NewCommandFaker()
+	s, err := NewFakeExecutor(f)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	var (
+		lock     sync.Mutex
+		probes   []string
+		detaches []string
+		pool     = "UUID\t\t\t\t\tHostname \tState\n" +
+			"8a2c6b1e-5d4f-4e8a-9b3c-1f2e3d4c5b6a\tn2 \tConnected \n" +
+			"0f3d5e7a-1b2c-4d3e-8f9a-0b1c2d3e4f5a\tlocalhost\tConnected \n"
+		badDetach bool
+	)
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		tests.Assert(t, host == "seed:22", host)
+		tests.Assert(t, len(commands) == 1)
+
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case strings.HasPrefix(commands[0], "gluster peer probe "):
+			node := strings.TrimPrefix(commands[0], "gluster peer probe ")
+			probes = append(probes, node)
+			if strings.HasPrefix(node, "bad") {
+				return nil, errors.New("peer probe: failed")
+			}
+		case strings.HasPrefix(commands[0], "gluster peer detach "):
+			detaches = append(detaches,
+				strings.TrimPrefix(commands[0], "gluster peer detach "))
+			if badDetach {
+				return nil, errors.New("peer detach: failed")
+			}
+		case commands[0] == "gluster --mode=script pool list":
+			return []string{pool}, nil
+		default:
+			tests.Assert(t, false, "unexpected command", commands)
+		}
+		return nil, nil
+	}
+
+	// The seed and duplicates are not probed
+	err = s.ClusterFormation("seed",
+		[]string{"n1", "n2", "seed", "n3", "n1"}, true)
+	tests.Assert(t, err == nil, err)
+	sort.Strings(probes)
+	tests.Assert(t, reflect.DeepEqual(probes, []string{"n1", "n2", "n3"}), probes)
+	tests.Assert(t, len(detaches) == 0, detaches)
+
+	// Failures are reported per node and nothing is rolled back
+	probes = nil
+	err = s.ClusterFormation("seed", []string{"n1", "bad1", "n2", "bad2"}, false)
+	tests.Assert(t, err != nil)
+	cerr, ok := err.(*executors.ClusterFormationError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, len(cerr.Failed) == 2, cerr.Failed)
+	tests.Assert(t, cerr.Failed["bad1"] != nil && cerr.Failed["bad2"] != nil)
+	tests.Assert(t, !cerr.RolledBack)
+	tests.Assert(t, len(probes) == 4, probes)
+	tests.Assert(t, len(detaches) == 0, detaches)
+
+	// With rollback only the nodes which were not peers before are
+	// detached
+	err = s.ClusterFormation("seed", []string{"n1", "bad1", "n2", "n3"}, true)
+	cerr, ok = err.(*executors.ClusterFormationError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, cerr.RolledBack)
+	tests.Assert(t, len(cerr.DetachFailed) == 0, cerr.DetachFailed)
+	tests.Assert(t, len(cerr.Failed) == 1, cerr.Failed)
+	sort.Strings(detaches)
+	tests.Assert(t, reflect.DeepEqual(detaches, []string{"n1", "n3"}), detaches)
+	tests.Assert(t, strings.Contains(err.Error(), "bad1: peer probe: failed"), err)
+
+	// Failed detaches are reported and the formation is not rolled back
+	detaches = nil
+	badDetach = true
+	err = s.ClusterFormation("seed", []string{"n1", "bad1"}, true)
+	cerr, ok = err.(*executors.ClusterFormationError)
+	tests.Assert(t, ok, err)
+	tests.Assert(t, !cerr.RolledBack)
+	tests.Assert(t, cerr.DetachFailed["n1"] != nil, cerr.DetachFailed)
+	tests.Assert(t, strings.Contains(err.Error(), "unable to detach 1 probed nodes"), err)
+
+	// Without the list of peers nothing is probed
+	probes = nil
+	pool = ""
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		if commands[0] == "gluster --mode=script pool list" {
+			return nil, errors.New("glusterd is not running")
+		}
+		lock.Lock()
+		probes = append(probes, commands[0])
+		lock.Unlock()
+		return nil, nil
+	}
+	err = s.ClusterFormation("seed", []string{"n1"}, true)
+	tests.Assert(t, err != nil && strings.Contains(err.Error(), "glusterd is not running"), err)
+	tests.Assert(t, len(probes) == 0, probes)
 }
@@ -311,3 +311,32 @@ func (s *CmdExecutor) HealInfo(host string, volume string) (*executors.HealInfo,
 	logger.Debug("%+v\n", healInfo)
 	return &healInfo.HealInfo, nil
 }
+
+func (s *CmdExecutor) VolumeStatus(host string, volume string) (*executors.VolumeStatus, error) {
+
+	godbc.Require(volume != "")
+	godbc.Require(host != "")
+
+	type CliOutput struct {
+		OpRet     int                 `xml:"opRet"`
+		OpErrno   int                 `xml:"opErrno"`
+		OpErrStr  string              `xml:"opErrstr"`
+		VolStatus executors.VolStatus `xml:"volStatus"`
+	}
+
+	command := []string{
+		fmt.Sprintf("gluster --mode=script volume status %v --xml", volume),
+	}
+
+	output, err := s.RemoteExecutor.RemoteCommandExecute(host, command, 10)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get status of volume : %v", volume)
+	}
+	var volStatus CliOutput
+	err = xml.Unmarshal([]byte(output[0]), &volStatus)
+	if err != nil || len(volStatus.VolStatus.VolumeList) == 0 {
+		return nil, fmt.Errorf("Unable to determine status of volume : %v", volume)
+	}
+	logger.Debug("%+v\n", volStatus)
+	return &volStatus.VolStatus.VolumeList[0], nil
+}
@@ -0,0 +1,108 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package cmdexec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/heketi/tests"
+)
+
+const volumeStatusXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <opRet>0</opRet>
+  <opErrno>0</opErrno>
+  <opErrstr/>
+  <volStatus>
+    <volumes>
+      <volume>
+        <volName>vol_abc</volName>
+        <nodeCount>3</nodeCount>
+        <node>
+          <hostname>192.168.10.100</hostname>
+          <path>/var/lib/heketi/mounts/vg_1/brick_1/brick</path>
+          <peerid>ae6e8e6a-0ff5-4d6b-9e3f-0d8b4bd4d2a1</peerid>
+          <status>1</status>
+          <port>49152</port>
+          <pid>1234</pid>
+        </node>
+        <node>
+          <hostname>192.168.10.101</hostname>
+          <path>/var/lib/heketi/mounts/vg_2/brick_2/brick</path>
+          <peerid>0b1c7f4e-3a52-4f0c-8d49-1e7d1a9f6c55</peerid>
+          <status>0</status>
+          <port>N/A</port>
+          <pid>-1</pid>
+        </node>
+        <node>
+          <hostname>Self-heal Daemon</hostname>
+          <path>192.168.10.100</path>
+          <peerid>ae6e8e6a-0ff5-4d6b-9e3f-0d8b4bd4d2a1</peerid>
+          <status>1</status>
+          <port>N/A</port>
+          <pid>2345</pid>
+        </node>
+      </volume>
+    </volumes>
+  </volStatus>
+</cliOutput>`
+
+func TestSshExecVolumeStatus(t *testing.T) {
+	f := NewCommandFaker()
+	s, err := NewFakeExecutor(f)
+	tests.Assert(t, err == nil)
+	tests.Assert(t, s != nil)
+
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		tests.Assert(t, host == "myhost:22", host)
+		tests.Assert(t, len(commands) == 1)
+		tests.Assert(t,
+			commands[0] == "gluster --mode=script volume status vol_abc --xml",
+			commands)
+		return []string{volumeStatusXml}, nil
+	}
+
+	status, err := s.VolumeStatus("myhost", "vol_abc")
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, status.VolumeName == "vol_abc", status.VolumeName)
+	tests.Assert(t, len(status.Nodes) == 3, status.Nodes)
+	tests.Assert(t, status.Nodes[0].Hostname == "192.168.10.100")
+	tests.Assert(t, status.Nodes[0].Path == "/var/lib/heketi/mounts/vg_1/brick_1/brick")
+	tests.Assert(t, status.Nodes[0].Online())
+	tests.Assert(t, !status.Nodes[1].Online())
+	tests.Assert(t, status.Nodes[2].Hostname == "Self-heal Daemon")
+
+	// Output that is not a volume status
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		return []string{"<cliOutput><opRet>-1</opRet></cliOutput>"}, nil
+	}
+	_, err = s.VolumeStatus("myhost", "vol_abc")
+	tests.Assert(t, err != nil)
+
+	// Failed command
+	f.FakeConnectAndExec = func(host string,
+		commands []string,
+		timeoutMinutes int,
+		useSudo bool) ([]string, error) {
+
+		return nil, errors.New("Volume vol_abc does not exist")
+	}
+	_, err = s.VolumeStatus("myhost", "vol_abc")
+	tests.Assert(t, err != nil)
+}
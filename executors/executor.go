@@ -9,15 +9,22 @@
 
 package executors
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 type Executor interface {
 	GlusterdCheck(host string) error
 	PeerProbe(exec_host, newnode string) error
 	PeerDetach(exec_host, detachnode string) error
+	ClusterFormation(seedHost string, newNodes []string, rollback bool) error
 	DeviceSetup(host, device, vgid string) (*DeviceInfo, error)
 	GetDeviceInfo(host, device, vgid string) (*DeviceInfo, error)
 	DeviceTeardown(host, device, vgid string) error
+	DeviceBricks(host, vgid string) ([]string, error)
 	BrickCreate(host string, brick *BrickRequest) (*BrickInfo, error)
 	BrickDestroy(host string, brick *BrickRequest) error
 	BrickDestroyCheck(host string, brick *BrickRequest) error
@@ -28,11 +35,53 @@ type Executor interface {
 	VolumeReplaceBrick(host string, volume string, oldBrick *BrickInfo, newBrick *BrickInfo) error
 	VolumeInfo(host string, volume string) (*Volume, error)
 	HealInfo(host string, volume string) (*HealInfo, error)
+	VolumeStatus(host string, volume string) (*VolumeStatus, error)
 	SetLogLevel(level string)
 	BlockVolumeCreate(host string, blockVolume *BlockVolumeRequest) (*BlockVolumeInfo, error)
 	BlockVolumeDestroy(host string, blockHostingVolumeName string, blockVolumeName string) error
 }
 
+// Returned by ClusterFormation when some of the nodes could not be
+// probed. Failed maps each of those nodes to its error.
+type ClusterFormationError struct {
+	Failed map[string]error
+
+	// Set if all the nodes which were added to the pool by this call
+	// were detached again. Nodes which were peers before are never
+	// detached.
+	RolledBack bool
+
+	// Nodes added by this call which could not be detached again
+	DetachFailed map[string]error
+}
+
+func (e *ClusterFormationError) Error() string {
+	s := fmt.Sprintf("Unable to probe %v nodes: %v",
+		len(e.Failed), formatNodeErrors(e.Failed))
+	if e.RolledBack {
+		s += " (probed nodes were detached)"
+	}
+	if len(e.DetachFailed) != 0 {
+		s += fmt.Sprintf("; unable to detach %v probed nodes: %v",
+			len(e.DetachFailed), formatNodeErrors(e.DetachFailed))
+	}
+	return s
+}
+
+func formatNodeErrors(errs map[string]error) string {
+	nodes := make([]string, 0, len(errs))
+	for node := range errs {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	msgs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", node, errs[node]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
 // Enumerate durability types
 type DurabilityType int
 
@@ -65,6 +114,16 @@ type BrickRequest struct {
 type BrickInfo struct {
 	Path string
 	Host string
+
+	// Allocation details, set by BrickCreate
+	LvPath    string
+	ThinPool  string
+	MountPath string
+
+	// Size in KB of the logical volume as provisioned on the node.  LVM
+	// rounds the requested size up to a multiple of the volume group
+	// extent size, so this may be larger than the size requested.
+	Size uint64
 }
 
 type VolumeRequest struct {
@@ -151,6 +210,35 @@ type HealInfo struct {
 	Bricks  HealInfoBricks `xml:"bricks"`
 }
 
+// Status of a brick process as listed by gluster volume status. The
+// self-heal and other daemons of the volume are listed the same way,
+// with the name of the daemon as Hostname.
+type BrickProcessStatus struct {
+	Hostname string `xml:"hostname"`
+	Path     string `xml:"path"`
+	PeerID   string `xml:"peerid"`
+	Status   int    `xml:"status"`
+	Port     string `xml:"port"`
+	Pid      int    `xml:"pid"`
+}
+
+// Online is true if gluster reports the process as running
+func (b *BrickProcessStatus) Online() bool {
+	return b.Status == 1
+}
+
+type VolumeStatus struct {
+	XMLName    xml.Name             `xml:"volume"`
+	VolumeName string               `xml:"volName"`
+	NodeCount  int                  `xml:"nodeCount"`
+	Nodes      []BrickProcessStatus `xml:"node"`
+}
+
+type VolStatus struct {
+	XMLName    xml.Name       `xml:"volStatus"`
+	VolumeList []VolumeStatus `xml:"volumes>volume"`
+}
+
 type BlockVolumeRequest struct {
 	Name              string
 	Size              int
@@ -15,6 +15,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	restclient "k8s.io/client-go/rest"
@@ -115,9 +116,16 @@ func NewKubeExecutor(config *KubeConfig) (*KubeExecutor, error) {
 	} else {
 		k.Fstab = config.Fstab
 	}
+	k.BrickOpsPerNode = config.BrickOpsPerNode
+	k.BrickOpsTimeout = time.Duration(config.BrickOpsTimeout) * time.Second
+
+	check, err := config.GlusterdCheckCmd()
+	if err != nil {
+		return nil, err
+	}
+	k.GlusterdCheckCommand = check
 
 	// Get namespace
-	var err error
 	if k.config.Namespace == "" {
 		k.config.Namespace, err = kubernetes.GetNamespace()
 		if err != nil {
@@ -18,8 +18,10 @@ type MockExecutor struct {
 	MockGlusterdCheck      func(host string) error
 	MockPeerProbe          func(exec_host, newnode string) error
 	MockPeerDetach         func(exec_host, newnode string) error
+	MockClusterFormation   func(seedHost string, newNodes []string, rollback bool) error
 	MockDeviceSetup        func(host, device, vgid string) (*executors.DeviceInfo, error)
 	MockDeviceTeardown     func(host, device, vgid string) error
+	MockDeviceBricks       func(host, vgid string) ([]string, error)
 	MockBrickCreate        func(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error)
 	MockBrickDestroy       func(host string, brick *executors.BrickRequest) error
 	MockBrickDestroyCheck  func(host string, brick *executors.BrickRequest) error
@@ -30,6 +32,7 @@ type MockExecutor struct {
 	MockVolumeReplaceBrick func(host string, volume string, oldBrick *executors.BrickInfo, newBrick *executors.BrickInfo) error
 	MockVolumeInfo         func(host string, volume string) (*executors.Volume, error)
 	MockHealInfo           func(host string, volume string) (*executors.HealInfo, error)
+	MockVolumeStatus       func(host string, volume string) (*executors.VolumeStatus, error)
 	MockBlockVolumeCreate  func(host string, blockVolume *executors.BlockVolumeRequest) (*executors.BlockVolumeInfo, error)
 	MockBlockVolumeDestroy func(host string, blockHostingVolumeName string, blockVolumeName string) error
 }
@@ -49,6 +52,10 @@ func NewMockExecutor() (*MockExecutor, error) {
 		return nil
 	}
 
+	m.MockClusterFormation = func(seedHost string, newNodes []string, rollback bool) error {
+		return nil
+	}
+
 	m.MockDeviceSetup = func(host, device, vgid string) (*executors.DeviceInfo, error) {
 		d := &executors.DeviceInfo{}
 		d.Size = 500 * 1024 * 1024 // Size in KB
@@ -60,9 +67,17 @@ func NewMockExecutor() (*MockExecutor, error) {
 		return nil
 	}
 
+	m.MockDeviceBricks = func(host, vgid string) ([]string, error) {
+		return []string{}, nil
+	}
+
 	m.MockBrickCreate = func(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error) {
 		b := &executors.BrickInfo{
-			Path: "/mockpath",
+			Path:      "/mockpath",
+			LvPath:    "/mockpath/lv",
+			ThinPool:  "/mockpath/tp",
+			MountPath: "/mockpath",
+			Size:      brick.Size,
 		}
 		return b, nil
 	}
@@ -116,6 +131,10 @@ func NewMockExecutor() (*MockExecutor, error) {
 		return &executors.HealInfo{}, nil
 	}
 
+	m.MockVolumeStatus = func(host string, volume string) (*executors.VolumeStatus, error) {
+		return &executors.VolumeStatus{VolumeName: volume}, nil
+	}
+
 	m.MockBlockVolumeCreate = func(host string, blockVolume *executors.BlockVolumeRequest) (*executors.BlockVolumeInfo, error) {
 		var blockVolumeInfo executors.BlockVolumeInfo
 		blockVolumeInfo.BlockHosts = blockVolume.BlockHosts
@@ -156,6 +175,10 @@ func (m *MockExecutor) PeerDetach(exec_host, newnode string) error {
 	return m.MockPeerDetach(exec_host, newnode)
 }
 
+func (m *MockExecutor) ClusterFormation(seedHost string, newNodes []string, rollback bool) error {
+	return m.MockClusterFormation(seedHost, newNodes, rollback)
+}
+
 func (m *MockExecutor) DeviceSetup(host, device, vgid string) (*executors.DeviceInfo, error) {
 	return m.MockDeviceSetup(host, device, vgid)
 }
@@ -168,6 +191,10 @@ func (m *MockExecutor) DeviceTeardown(host, device, vgid string) error {
 	return m.MockDeviceTeardown(host, device, vgid)
 }
 
+func (m *MockExecutor) DeviceBricks(host, vgid string) ([]string, error) {
+	return m.MockDeviceBricks(host, vgid)
+}
+
 func (m *MockExecutor) BrickCreate(host string, brick *executors.BrickRequest) (*executors.BrickInfo, error) {
 	return m.MockBrickCreate(host, brick)
 }
@@ -208,6 +235,10 @@ func (m *MockExecutor) HealInfo(host string, volume string) (*executors.HealInfo
 	return m.MockHealInfo(host, volume)
 }
 
+func (m *MockExecutor) VolumeStatus(host string, volume string) (*executors.VolumeStatus, error) {
+	return m.MockVolumeStatus(host, volume)
+}
+
 func (m *MockExecutor) BlockVolumeCreate(host string, blockVolume *executors.BlockVolumeRequest) (*executors.BlockVolumeInfo, error) {
 	return m.MockBlockVolumeCreate(host, blockVolume)
 }
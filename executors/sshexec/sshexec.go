@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/heketi/heketi/executors/cmdexec"
 	"github.com/heketi/heketi/pkg/utils"
@@ -111,12 +112,19 @@ func NewSshExecutor(config *SshConfig) (*SshExecutor, error) {
 	} else {
 		s.Fstab = config.Fstab
 	}
+	s.BrickOpsPerNode = config.BrickOpsPerNode
+	s.BrickOpsTimeout = time.Duration(config.BrickOpsTimeout) * time.Second
+
+	check, err := config.GlusterdCheckCmd()
+	if err != nil {
+		return nil, err
+	}
+	s.GlusterdCheckCommand = check
 
 	// Save the configuration
 	s.config = config
 
 	// Setup key
-	var err error
 	s.exec, err = sshNew(s.Logger(), s.user, s.private_keyfile)
 	if err != nil {
 		s.Logger().Err(err)
@@ -204,3 +204,40 @@ func TestSshExecutorEnvVariables(t *testing.T) {
 	tests.Assert(t, s.exec != nil)
 
 }
+
+func TestSshExecGlusterdCheckConfig(t *testing.T) {
+
+	f := NewFakeSsh()
+	defer tests.Patch(&sshNew,
+		func(logger *utils.Logger, user string, file string) (Ssher, error) {
+			return f, nil
+		}).Restore()
+
+	config := &SshConfig{
+		PrivateKeyFile: "xkeyfile",
+	}
+	s, err := NewSshExecutor(config)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, s.GlusterdCheckCommand == "systemctl status glusterd",
+		s.GlusterdCheckCommand)
+
+	config.GlusterdCheck = cmdexec.GlusterdCheckPool
+	s, err = NewSshExecutor(config)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, s.GlusterdCheckCommand == "gluster --mode=script pool list",
+		s.GlusterdCheckCommand)
+
+	config.GlusterdCheck = cmdexec.GlusterdCheckCustom
+	s, err = NewSshExecutor(config)
+	tests.Assert(t, err != nil)
+	tests.Assert(t, s == nil)
+
+	config.GlusterdCheckCommand = "pgrep glusterd"
+	s, err = NewSshExecutor(config)
+	tests.Assert(t, err == nil, err)
+	tests.Assert(t, s.GlusterdCheckCommand == "pgrep glusterd", s.GlusterdCheckCommand)
+
+	config.GlusterdCheck = "ping"
+	_, err = NewSshExecutor(config)
+	tests.Assert(t, err != nil)
+}
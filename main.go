@@ -278,6 +278,9 @@ func main() {
 	// Negroni
 	n := negroni.New(negroni.NewRecovery(), negroni.NewLogger())
 
+	// Tag every request with an id which is returned to the client
+	n.Use(middleware.NewRequestID())
+
 	// Setup a new GlusterFS application
 	app := setupApp(fp)
 
@@ -0,0 +1,61 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/heketi/heketi/pkg/utils"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
+// RequestID makes sure every request has an id which is echoed back in
+// the response, so that clients can correlate their requests with the
+// server logs. An id sent by the client is kept if it is well formed,
+// otherwise a new one is generated.
+type RequestID struct{}
+
+func NewRequestID() *RequestID {
+	return &RequestID{}
+}
+
+func (m *RequestID) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = utils.GenUUID()
+		r.Header.Set(RequestIDHeader, id)
+	}
+	w.Header().Set(RequestIDHeader, id)
+
+	next(w, r)
+}
+
+// Only accept ids which are safe to write to logs and headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+//
+// Copyright (c) 2018 The heketi Authors
+//
+// This file is licensed to you under your choice of the GNU Lesser
+// General Public License, version 3 or any later version (LGPLv3 or
+// later), or the GNU General Public License, version 2 (GPLv2), in all
+// cases as published by the Free Software Foundation.
+//
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/heketi/tests"
+	"github.com/urfave/negroni"
+)
+
+func TestRequestID(t *testing.T) {
+
+	// Save the id the handler sees
+	var seen string
+	n := negroni.New(NewRequestID())
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get(RequestIDHeader)
+		http.Error(w, "Failed", http.StatusInternalServerError)
+	})
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	send := func(id string) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL, nil)
+		tests.Assert(t, err == nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		r, err := http.DefaultClient.Do(req)
+		tests.Assert(t, err == nil)
+		r.Body.Close()
+		return r
+	}
+
+	// A new id is generated and echoed, even for failed requests
+	r := send("")
+	tests.Assert(t, r.StatusCode == http.StatusInternalServerError)
+	id := r.Header.Get(RequestIDHeader)
+	tests.Assert(t, len(id) == 32, id)
+	tests.Assert(t, seen == id, seen, id)
+
+	// Every request gets its own id
+	r = send("")
+	tests.Assert(t, r.Header.Get(RequestIDHeader) != id)
+
+	// Ids sent by the client are kept
+	r = send("my-request.1")
+	tests.Assert(t, r.Header.Get(RequestIDHeader) == "my-request.1")
+	tests.Assert(t, seen == "my-request.1", seen)
+
+	// Malformed ids are replaced
+	for _, bad := range []string{"bad id", "x\"y", strings.Repeat("a", 129)} {
+		r = send(bad)
+		id = r.Header.Get(RequestIDHeader)
+		tests.Assert(t, id != bad && len(id) == 32, id)
+		tests.Assert(t, seen == id, seen, id)
+	}
+}
@@ -35,6 +35,12 @@ var (
 	volumeNameRe = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
 
 	blockVolNameRe = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+
+	// Tag keys of nodes and devices, such as "zone" or "example.com/rack"
+	tagKeyRe = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_./-]{0,62}$")
+
+	// Tag values are printable and may be empty
+	tagValueRe = regexp.MustCompile("^[[:print:]]{0,255}$")
 )
 
 // ValidateUUID is written this way because heketi UUID does not
@@ -48,6 +54,25 @@ func ValidateUUID(value interface{}) error {
 	return nil
 }
 
+// ValidateTags checks the keys and values of a map of tags
+func ValidateTags(value interface{}) error {
+	tags, _ := value.(map[string]string)
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !tagKeyRe.MatchString(key) {
+			return fmt.Errorf("%q is not a valid tag key", key)
+		}
+		if !tagValueRe.MatchString(tags[key]) {
+			return fmt.Errorf("%q is not a valid value for tag %v", tags[key], key)
+		}
+	}
+	return nil
+}
+
 // State
 type EntryState string
 
@@ -179,8 +204,15 @@ type DeviceInfo struct {
 
 type DeviceInfoResponse struct {
 	DeviceInfo
-	State  EntryState  `json:"state"`
-	Bricks []BrickInfo `json:"bricks"`
+	State  EntryState        `json:"state"`
+	Bricks []BrickInfo       `json:"bricks"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// Ids of the bricks found on the node holding a device, by looking at
+// the logical volumes of the device rather than the heketi database
+type DeviceBrickScanResponse struct {
+	Bricks []string `json:"bricks"`
 }
 
 // Node
@@ -207,6 +239,28 @@ type NodeInfoResponse struct {
 	NodeInfo
 	State       EntryState           `json:"state"`
 	DevicesInfo []DeviceInfoResponse `json:"devices"`
+	Tags        map[string]string    `json:"tags,omitempty"`
+}
+
+// Replacement of all the tags of a node or device
+type TagsChangeRequest struct {
+	Tags map[string]string `json:"tags"`
+}
+
+func (tagsChangeReq TagsChangeRequest) Validate() error {
+	return validation.ValidateStruct(&tagsChangeReq,
+		validation.Field(&tagsChangeReq.Tags, validation.By(ValidateTags)),
+	)
+}
+
+// Result of checking that glusterd answers on a node
+type NodePingResponse struct {
+	Id     string `json:"id"`
+	Up     bool   `json:"up"`
+	Reason string `json:"reason,omitempty"`
+
+	// Time taken by the check in milliseconds
+	Latency int64 `json:"latency_ms"`
 }
 
 // Cluster
@@ -309,6 +363,7 @@ type VolumeInfo struct {
 		FreeSize     int              `json:"freesize,omitempty"`
 		BlockVolumes sort.StringSlice `json:"blockvolume,omitempty"`
 	} `json:"blockinfo,omitempty"`
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 type VolumeInfoResponse struct {
@@ -316,6 +371,28 @@ type VolumeInfoResponse struct {
 	Bricks []BrickInfo `json:"bricks"`
 }
 
+// Brick ids of each replica or disperse set of a volume, in the order
+// gluster uses them
+type VolumeBrickSetsResponse struct {
+	Sets [][]string `json:"sets"`
+}
+
+// Brick a volume create would place. Size in KB.
+type BrickPlacement struct {
+	Set      int    `json:"set"`
+	NodeId   string `json:"node"`
+	DeviceId string `json:"device"`
+	Size     uint64 `json:"size"`
+}
+
+// Plan of a volume create which was not carried out
+type VolumePlacement struct {
+	Feasible  bool             `json:"feasible"`
+	Reason    string           `json:"reason,omitempty"`
+	ClusterId string           `json:"cluster,omitempty"`
+	Bricks    []BrickPlacement `json:"bricks,omitempty"`
+}
+
 type VolumeListResponse struct {
 	Volumes []string `json:"volumes"`
 }
@@ -330,6 +407,89 @@ func (volExpandReq VolumeExpandRequest) Validate() error {
 	)
 }
 
+// Change of the name or tags of a volume. Only the set fields are
+// changed; an empty, non-nil map of tags removes all the tags.
+type VolumeUpdateRequest struct {
+	Name string            `json:"name,omitempty"`
+	Tags map[string]string `json:"tags"`
+}
+
+func (volUpdateReq VolumeUpdateRequest) Validate() error {
+	return validation.ValidateStruct(&volUpdateReq,
+		validation.Field(&volUpdateReq.Name, validation.Match(volumeNameRe)),
+		validation.Field(&volUpdateReq.Tags, validation.By(ValidateTags)),
+	)
+}
+
+// Deletion of a volume once a grace period, in seconds, has passed.
+// Until then the deletion can be cancelled by restoring the volume.
+type VolumeDeleteScheduleRequest struct {
+	Grace int `json:"grace"`
+}
+
+func (volDeleteReq VolumeDeleteScheduleRequest) Validate() error {
+	return validation.ValidateStruct(&volDeleteReq,
+		validation.Field(&volDeleteReq.Grace, validation.Required, validation.Min(1)),
+	)
+}
+
+// Time in RFC 3339 format at which a volume will be deleted
+type VolumeDeleteScheduleResponse struct {
+	Id       string `json:"id"`
+	DeleteAt string `json:"delete_at"`
+}
+
+// Rebalance states
+const (
+	RebalanceInProgress = "in progress"
+	RebalanceCompleted  = "completed"
+	RebalanceFailed     = "failed"
+)
+
+// Progress of a volume rebalance
+type VolumeRebalanceStatus struct {
+	State           string `json:"state"`
+	PercentComplete int    `json:"percent_complete"`
+	FilesMoved      uint64 `json:"files_moved"`
+	FilesFailed     uint64 `json:"files_failed"`
+}
+
+// Status of a brick process as reported by gluster
+type BrickStatus struct {
+	Id     string `json:"id"`
+	Online bool   `json:"online"`
+}
+
+type VolumeBrickStatusResponse struct {
+	Bricks []BrickStatus `json:"bricks"`
+}
+
+// Usage of the file system of a brick. Sizes in bytes. The usage of
+// bricks which are not online is not reported.
+type BrickUtilization struct {
+	Id          string `json:"id"`
+	Online      bool   `json:"online"`
+	BytesUsed   uint64 `json:"bytes_used"`
+	BytesTotal  uint64 `json:"bytes_total"`
+	InodesUsed  uint64 `json:"inodes_used"`
+	InodesTotal uint64 `json:"inodes_total"`
+}
+
+type VolumeBrickUtilizationResponse struct {
+	Bricks []BrickUtilization `json:"bricks"`
+}
+
+// Self-heal state of a brick as reported by gluster heal info
+type BrickHealInfo struct {
+	Id              string   `json:"id"`
+	PendingHeals    uint64   `json:"pending_heals"`
+	SplitBrainFiles []string `json:"split_brain_files,omitempty"`
+}
+
+type VolumeHealInfoResponse struct {
+	Bricks []BrickHealInfo `json:"bricks"`
+}
+
 // BlockVolume
 
 type BlockVolumeCreateRequest struct {
@@ -381,6 +541,76 @@ type LogLevelInfo struct {
 	LogLevel map[string]string `json:"loglevel"`
 }
 
+// Server
+
+type ServerInfoResponse struct {
+	Version string `json:"version"`
+	// API versions the server accepts
+	APIVersions []int `json:"api_versions"`
+}
+
+// Operations
+
+// OperationEvent is a single entry in the event trail of an operation
+type OperationEvent struct {
+	// Time of the event in seconds since the epoch
+	Time    int64  `json:"time"`
+	State   string `json:"state"`
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+}
+
+type OperationLogResponse struct {
+	Id     string           `json:"id"`
+	Events []OperationEvent `json:"events"`
+}
+
+// Operation states. An operation is unknown when the server has no
+// record of it, either because the id is wrong or because the server
+// already forgot the completed operation.
+const (
+	OperationPending   = "pending"
+	OperationCompleted = "completed"
+	OperationFailed    = "failed"
+	OperationUnknown   = "unknown"
+)
+
+type OperationInfo struct {
+	Id    string `json:"id"`
+	Type  string `json:"type"`
+	State string `json:"state"`
+	// Start time in seconds since the epoch
+	StartTime int64 `json:"start_time"`
+}
+
+// Progress of an asynchronous operation, as streamed by the server
+type OperationProgress struct {
+	State           string `json:"state"`
+	PercentComplete int    `json:"percent_complete"`
+	Message         string `json:"message,omitempty"`
+}
+
+// Status of an asynchronous operation. Location is the resource a
+// completed operation created or changed, if any, and Error the reason
+// a failed operation failed.
+type OperationStatus struct {
+	Id       string `json:"id"`
+	State    string `json:"state"`
+	Location string `json:"location,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type OperationsStatusResponse struct {
+	Operations []OperationStatus `json:"operations"`
+}
+
+// One page of operations. If Next is set, more operations are listed by
+// passing it back as the marker of the next request.
+type OperationListResponse struct {
+	Operations []OperationInfo `json:"operations"`
+	Next       string          `json:"next,omitempty"`
+}
+
 // Constructors
 
 func NewVolumeInfoResponse() *VolumeInfoResponse {
@@ -10,13 +10,28 @@
 package utils
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
+const (
+	// Largest error body read when the length is not known
+	maxErrorBody = 64 * 1024
+
+	// Longest part of an error body kept in the error, in bytes
+	maxErrorSnippet = 256
+)
+
+var htmlTags = regexp.MustCompile(`(?s)<[^>]*>`)
+
 // Return the body from a response as a string
 func GetStringFromResponse(r *http.Response) (string, error) {
 	body, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength))
@@ -27,11 +42,75 @@ func GetStringFromResponse(r *http.Response) (string, error) {
 	return string(body), nil
 }
 
-// Return the body from a response as an error
+// Return the body from a response as an error.
+//
+// Plain text bodies, which is how Heketi reports its own errors, and
+// bodies without a content type are returned unchanged. JSON and HTML
+// bodies, which normally come from a proxy or gateway in front of
+// Heketi, are reported with the status followed by the message from
+// the JSON body or the text of the HTML body, cut to a short snippet.
+// An empty body is reported with just the status.
 func GetErrorFromResponse(r *http.Response) error {
-	s, err := GetStringFromResponse(r)
+	limit := r.ContentLength
+	if limit < 0 {
+		limit = maxErrorBody
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, limit))
+	r.Body.Close()
 	if err != nil {
 		return err
 	}
-	return errors.New(strings.TrimSpace(s))
+	s := strings.TrimSpace(string(body))
+
+	status := fmt.Sprintf("%v %v", r.StatusCode, http.StatusText(r.StatusCode))
+	mediatype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case s == "":
+		return errors.New(status)
+
+	case mediatype == "text/plain":
+		return errors.New(s)
+
+	case mediatype == "application/json":
+		if msg := jsonErrorMessage(s); msg != "" {
+			return fmt.Errorf("%v: %v", status, msg)
+		}
+		return fmt.Errorf("%v: %v", status, truncateSnippet(s))
+
+	case mediatype == "text/html" || strings.HasPrefix(s, "<"):
+		text := strings.Join(strings.Fields(htmlTags.ReplaceAllString(s, " ")), " ")
+		if text == "" {
+			return errors.New(status)
+		}
+		return fmt.Errorf("%v: %v", status, truncateSnippet(text))
+
+	default:
+		return errors.New(s)
+	}
+}
+
+// Return the message of a JSON error body such as {"error": "..."}
+func jsonErrorMessage(s string) string {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &body); err != nil {
+		return ""
+	}
+	for _, key := range []string{"error", "message", "msg"} {
+		if msg, ok := body[key].(string); ok && msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// Cut s to maxErrorSnippet bytes, without splitting a character
+func truncateSnippet(s string) string {
+	if len(s) <= maxErrorSnippet {
+		return s
+	}
+	cut := maxErrorSnippet
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
 }
@@ -13,7 +13,9 @@ import (
 	"bytes"
 	"errors"
 	"net/http"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/heketi/tests"
 )
@@ -117,3 +119,110 @@ func TestGetErrorFromResponseErrStringSpace(t *testing.T) {
 	tests.Assert(t, err.Error() == "whoa nellie",
 		`expected err.Error() == "whoa nellie", got:`, err.Error())
 }
+
+func TestGetErrorFromResponseJson(t *testing.T) {
+	bodytext := `{"error": "upstream unavailable", "code": 17}`
+	resp := &http.Response{
+		StatusCode:    503,
+		Header:        http.Header{"Content-Type": {"application/json; charset=UTF-8"}},
+		Body:          dummyCloser{bytes.NewBufferString(bodytext)},
+		ContentLength: int64(len(bodytext)),
+	}
+	err := GetErrorFromResponse(resp)
+	tests.Assert(t, err != nil, "expected err != nil, got:", err)
+	tests.Assert(t, err.Error() == "503 Service Unavailable: upstream unavailable",
+		"got:", err.Error())
+
+	// JSON without a recognized message is kept as is
+	bodytext = `{"code": 17}`
+	resp = &http.Response{
+		StatusCode:    503,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          dummyCloser{bytes.NewBufferString(bodytext)},
+		ContentLength: int64(len(bodytext)),
+	}
+	err = GetErrorFromResponse(resp)
+	tests.Assert(t, err.Error() == `503 Service Unavailable: {"code": 17}`,
+		"got:", err.Error())
+}
+
+func TestGetErrorFromResponseHtml(t *testing.T) {
+	bodytext := "<html>\r\n<head><title>502 Bad Gateway</title></head>\r\n" +
+		"<body bgcolor=\"white\">\r\n<center><h1>502 Bad Gateway</h1></center>\r\n" +
+		"<hr><center>nginx</center>\r\n</body>\r\n</html>\r\n"
+	resp := &http.Response{
+		StatusCode:    502,
+		Header:        http.Header{"Content-Type": {"text/html"}},
+		Body:          dummyCloser{bytes.NewBufferString(bodytext)},
+		ContentLength: -1,
+	}
+	err := GetErrorFromResponse(resp)
+	tests.Assert(t, err != nil, "expected err != nil, got:", err)
+	tests.Assert(t,
+		err.Error() == "502 Bad Gateway: 502 Bad Gateway 502 Bad Gateway nginx",
+		"got:", err.Error())
+
+	// Long bodies are truncated
+	bodytext = "<html><body>" + strings.Repeat("x", 1000) + "</body></html>"
+	resp = &http.Response{
+		StatusCode:    500,
+		Body:          dummyCloser{bytes.NewBufferString(bodytext)},
+		ContentLength: int64(len(bodytext)),
+	}
+	err = GetErrorFromResponse(resp)
+	tests.Assert(t, err.Error() == "500 Internal Server Error: "+
+		strings.Repeat("x", maxErrorSnippet)+"...", "got:", err.Error())
+}
+
+func TestGetErrorFromResponseText(t *testing.T) {
+	bodytext := "Id not found\n"
+	resp := &http.Response{
+		StatusCode:    404,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          dummyCloser{bytes.NewBufferString(bodytext)},
+		ContentLength: -1,
+	}
+	err := GetErrorFromResponse(resp)
+	tests.Assert(t, err != nil, "expected err != nil, got:", err)
+	tests.Assert(t, err.Error() == "Id not found", "got:", err.Error())
+	tests.Assert(t, resp.ContentLength == -1, resp.ContentLength)
+
+	// Long bodies are kept whole
+	bodytext = "Unable to create brick: " + strings.Repeat("x", 1000)
+	resp = &http.Response{
+		StatusCode:    500,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          dummyCloser{bytes.NewBufferString(bodytext)},
+		ContentLength: int64(len(bodytext)),
+	}
+	err = GetErrorFromResponse(resp)
+	tests.Assert(t, err.Error() == bodytext, "got:", err.Error())
+}
+
+func TestGetErrorFromResponseSnippet(t *testing.T) {
+	// Long bodies are cut without splitting a character
+	bodytext := "<p>x" + strings.Repeat("é", maxErrorSnippet) + "</p>"
+	resp := &http.Response{
+		StatusCode:    502,
+		Header:        http.Header{"Content-Type": {"text/html"}},
+		Body:          dummyCloser{bytes.NewBufferString(bodytext)},
+		ContentLength: int64(len(bodytext)),
+	}
+	err := GetErrorFromResponse(resp)
+	msg := strings.TrimPrefix(err.Error(), "502 Bad Gateway: ")
+	tests.Assert(t, utf8.ValidString(msg), msg)
+	tests.Assert(t, msg == "x"+strings.Repeat("é", (maxErrorSnippet-1)/2)+"...",
+		"got:", msg)
+}
+
+func TestGetErrorFromResponseEmpty(t *testing.T) {
+	resp := &http.Response{
+		StatusCode:    500,
+		Body:          dummyCloser{bytes.NewBufferString("")},
+		ContentLength: 0,
+	}
+	err := GetErrorFromResponse(resp)
+	tests.Assert(t, err != nil, "expected err != nil, got:", err)
+	tests.Assert(t, err.Error() == "500 Internal Server Error",
+		"got:", err.Error())
+}